@@ -2,8 +2,10 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -13,25 +15,211 @@ import (
 const (
 	leaderRole   = "leader"
 	followerRole = "follower"
+	cancelRole   = "cancel"
+	// verifyManifestRole checks a signed manifest offline, without a Vault URL
+	verifyManifestRole = "verify-manifest"
+	// scanRole reassembles an encrypted share from the QR codes of a printed share
+	scanRole = "scan"
 )
 
+const usage = "Usage: locksmith <leader|follower|cancel> [flags] [vault url]\n       locksmith verify-manifest [flags] <manifest>\n       locksmith scan [flags] <image>..."
+
+type options struct {
+	tokenFile        string
+	namespace        string
+	tls              locksmith.TLSConfig
+	keyType          string
+	keyserver        string
+	rosterFile       string
+	mask             bool
+	decrypt          bool
+	keysFile         string
+	participant      string
+	privateKey       string
+	share            locksmith.ShareSource
+	configFile       string
+	ceremony         *locksmith.Ceremony
+	outputDir        string
+	outputFormat     string
+	sinks            stringList
+	signingKey       string
+	signatureFile    string
+	publicKeyFile    string
+	scanOutput       string
+	cluster          locksmith.ClusterInfo
+	resume           bool
+	stateFile        string
+	yes              bool
+	verificationOnly bool
+}
+
 func main() {
 	args := os.Args[1:]
-	if !validArgs(args) {
-		fmt.Println("Usage: locksmith <leader|follower> <vault url>")
+	if len(args) == 0 || !validRole(args[0]) {
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 	role := args[0]
-	vaultURL := args[1]
+
+	var opts options
+	flags := flag.NewFlagSet(role, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+	}
+	if role == verifyManifestRole {
+		flags.StringVar(&opts.publicKeyFile, "public-key", "", "trusted signer keys: a PGP public key or keyring, or SSH keys in authorized_keys or allowed_signers format")
+		flags.StringVar(&opts.signatureFile, "signature", "", "detached signature of the manifest (defaults to the manifest path with .sig appended)")
+		flags.StringVar(&opts.keysFile, "keys-file", "", "key file, directory of armored shares, or a single armored share to check against the manifest hashes")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		err := executeVerifyManifest(flags.Arg(0), opts)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if role == scanRole {
+		flags.StringVar(&opts.scanOutput, "output", "", "file to write the armored encrypted share to (defaults to <key type>-share-<sha256 prefix>.asc)")
+		flags.Parse(args[1:])
+		if flags.NArg() == 0 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		err := executeScan(flags.Args(), opts)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+	flags.StringVar(&opts.tokenFile, "token-file", "", "path to a file containing a Vault token (defaults to VAULT_TOKEN, then ~/.vault-token)")
+	flags.StringVar(&opts.namespace, "namespace", os.Getenv("VAULT_NAMESPACE"), "Vault Enterprise namespace (defaults to VAULT_NAMESPACE)")
+	tlsEnv := locksmith.TLSConfigFromEnv()
+	flags.StringVar(&opts.tls.CACert, "ca-cert", tlsEnv.CACert, "path to a PEM CA certificate used to verify Vault (defaults to VAULT_CACERT)")
+	flags.StringVar(&opts.tls.CAPath, "ca-path", tlsEnv.CAPath, "path to a directory of PEM CA certificates (defaults to VAULT_CAPATH)")
+	flags.StringVar(&opts.tls.ClientCert, "client-cert", tlsEnv.ClientCert, "path to a PEM client certificate for mTLS (defaults to VAULT_CLIENT_CERT)")
+	flags.StringVar(&opts.tls.ClientKey, "client-key", tlsEnv.ClientKey, "path to the PEM private key for -client-cert (defaults to VAULT_CLIENT_KEY)")
+	flags.StringVar(&opts.tls.ServerName, "tls-server-name", tlsEnv.ServerName, "server name used for SNI and certificate verification (defaults to VAULT_TLS_SERVER_NAME)")
+	flags.BoolVar(&opts.tls.Insecure, "tls-skip-verify", tlsEnv.Insecure, "disable TLS certificate verification (defaults to VAULT_SKIP_VERIFY)")
+	flags.StringVar(&opts.keyType, "key-type", string(locksmith.AutoKeyType), "keys to rekey: recovery, unseal, or auto to detect from the seal status")
+	if role == leaderRole || role == followerRole {
+		flags.BoolVar(&opts.mask, "mask", false, "echo an asterisk for each character of a key share instead of hiding input")
+		flags.BoolVar(&opts.decrypt, "decrypt", false, "enter the encrypted new key share during verification and decrypt it locally")
+		flags.StringVar(&opts.keysFile, "keys-file", "", "key file to read the encrypted new key share from during verification, requires -participant")
+		flags.StringVar(&opts.participant, "participant", "", "your participant name in the key file")
+		flags.StringVar(&opts.privateKey, "private-key", "", "PGP private key file used to decrypt the new key share instead of gpg")
+		flags.StringVar(&opts.share.File, "share-file", "", "read the key share from a file instead of prompting")
+		flags.BoolVar(&opts.share.Stdin, "share-stdin", false, "read the key share from the first line of stdin instead of prompting")
+		flags.StringVar(&opts.share.Env, "share-env", "", "read the key share from the named environment variable instead of prompting")
+		flags.BoolVar(&opts.share.Shred, "shred-share-file", false, "overwrite and delete the -share-file once the key share is accepted")
+	}
+	if role == leaderRole {
+		flags.StringVar(&opts.keyserver, "keyserver", "", "HKP keyserver used to resolve hkp: participants, e.g. hkps://keys.example.com")
+		flags.StringVar(&opts.rosterFile, "roster", "", "JSON file pinning each participant to their expected PGP fingerprint")
+		flags.StringVar(&opts.outputDir, "output-dir", "", "directory to write the new key file to, created with mode 0700 if missing (defaults to the current directory)")
+		flags.StringVar(&opts.outputFormat, "output-format", string(locksmith.TextKeyFileFormat), "key file format: text, json for a versioned document other tools can parse, armored for one share file per participant, or paper to add a printable page with QR codes per participant")
+		flags.StringVar(&opts.signingKey, "signing-key", "", "PGP or OpenSSH private key used to sign a manifest of the ceremony, written next to the key file")
+		flags.Var(&opts.sinks, "sink", "additional place to store the keys, may be repeated: file:dir, s3://bucket/prefix, vault-kv://mount/path or exec:command")
+		flags.BoolVar(&opts.resume, "resume", false, "resume an interrupted ceremony from the last phase saved to the state file")
+		flags.StringVar(&opts.stateFile, "state-file", "", "file recording the ceremony so it can be resumed, encrypted with the passphrase in "+locksmith.StatePassphraseEnv+" or entered at a prompt (defaults to "+locksmith.DefaultStateFile+" in the output directory)")
+		flags.StringVar(&opts.configFile, "config", "", "YAML, JSON or HCL ceremony file defining the rekey, instead of prompting")
+	}
+	if role == cancelRole {
+		flags.BoolVar(&opts.yes, "yes", false, "skip the confirmation prompt")
+		flags.BoolVar(&opts.verificationOnly, "verification-only", false, "restart verification instead of cancelling the whole rekey")
+	}
+	flags.Parse(args[1:])
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	if opts.keysFile != "" && opts.participant == "" {
+		printError(errors.New("-keys-file requires -participant"))
+		os.Exit(1)
+	}
+	if opts.keysFile != "" || opts.privateKey != "" {
+		opts.decrypt = true
+	}
+	err := opts.share.Validate()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	// Reading the key share unattended is pointless if verification prompts for the new one
+	if opts.share.Configured() && opts.keysFile == "" && !(role == leaderRole && opts.decrypt && opts.participant != "") {
+		printError(errors.New("-share-file, -share-stdin and -share-env require the new key share to be decrypted from a key file, pass -keys-file and -participant, or as the leader -decrypt and -participant"))
+		os.Exit(1)
+	}
+
+	// Fall back to the ceremony file, then VAULT_ADDR, when the URL is omitted
+	vaultURL := flags.Arg(0)
+	if opts.configFile != "" {
+		ceremony, err := locksmith.LoadCeremony(opts.configFile)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		applyCeremony(flags, &opts, ceremony)
+		if vaultURL == "" {
+			vaultURL = ceremony.VaultURL
+		}
+	}
+	if vaultURL == "" {
+		vaultURL = os.Getenv("VAULT_ADDR")
+	}
+	if vaultURL == "" {
+		printError(errors.New("no vault url provided, pass it as an argument or set VAULT_ADDR"))
+		os.Exit(1)
+	}
+
+	client, err := newClient(vaultURL, opts)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	keyType, err := client.DetectKeyType()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
 
 	fmt.Println("🔐 Welcome to Locksmith!")
+	if client.Namespace() != "" {
+		fmt.Printf("Using Vault namespace: %s\n", client.Namespace())
+	}
+	fmt.Printf("Key type: %s\n", keyType)
+
+	// Make sure the cluster can run this ceremony before anyone submits a key
+	if role != cancelRole {
+		info, err := client.Preflight()
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cluster: %s\n", info.Summary())
+		opts.cluster = info
+		if info.Health.Standby {
+			fmt.Println("⚠️  Connected to a standby node, requests will be forwarded to the active node.")
+		}
+	}
 	printProgressBar()
 
-	var err error
-	if role == leaderRole {
-		err = executeLeaderTrack(vaultURL)
-	} else {
-		err = executeFollowerTrack(vaultURL)
+	switch role {
+	case leaderRole:
+		err = executeLeaderTrack(client, opts)
+	case followerRole:
+		err = executeFollowerTrack(client, opts)
+	case cancelRole:
+		err = executeCancel(client, opts)
+	}
+	if errors.Is(err, locksmith.ErrInterrupted) {
+		os.Exit(130)
 	}
 	if err != nil {
 		printError(err)
@@ -41,88 +229,351 @@ func main() {
 	printProgressBar()
 }
 
-func executeLeaderTrack(vaultURL string) error {
+// leaderOutput is where the leader stores the new keys and the manifest.
+type leaderOutput struct {
+	format locksmith.KeyFileFormat
+	sinks  []locksmith.KeySink
+	signer locksmith.ManifestSigner
+}
+
+func executeLeaderTrack(client *locksmith.Client, opts options) error {
+	if opts.stateFile == "" {
+		opts.stateFile = filepath.Join(opts.outputDir, locksmith.DefaultStateFile)
+	}
+
+	output, err := loadLeaderOutput(opts)
+	if err != nil {
+		return err
+	}
+	if opts.resume {
+		return resumeLeaderTrack(client, opts, output)
+	}
+
+	// The new keys are saved to the state file as soon as Vault returns them, so a crash cannot lose them
+	stateFile, err := locksmith.NewStateFile(opts.stateFile)
+	if err != nil {
+		return err
+	}
+
 	// Check for existing rekey operation
-	status, err := locksmith.GetRekeyStatus(vaultURL)
+	status, err := client.GetRekeyStatus()
 	if err != nil {
 		return locksmith.WrapError(err, "failed to get rekey status")
 	}
 	if status.InProgress() {
-		return errors.New("a rekey operation is already in progress, please cancel operation before starting a new one")
+		return errors.New("a rekey operation is already in progress, run 'locksmith cancel' before starting a new one")
+	}
+
+	startedAt := time.Now().UTC()
+
+	var roster locksmith.Roster
+	if opts.rosterFile != "" {
+		roster, err = locksmith.LoadRoster(opts.rosterFile)
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("Starting a new rekey operation.")
 
 	// Build & submit request to start new rekey
-	rekeyRequest := locksmith.PromptRekeyOptions()
-	status, err = locksmith.StartRekey(vaultURL, rekeyRequest)
+	var rekeyRequest locksmith.StartRekeyRequest
+	if opts.ceremony != nil {
+		rekeyRequest, err = opts.ceremony.RekeyRequest()
+		if err != nil {
+			return err
+		}
+		roster, err = roster.Merge(opts.ceremony.Pins())
+		if err != nil {
+			return locksmith.WrapError(err, "ceremony file conflicts with roster")
+		}
+		fmt.Printf("Using ceremony file %s: %d shares, threshold %d.\n", opts.configFile, rekeyRequest.SecretShares, rekeyRequest.SecretThreshold)
+	} else {
+		rekeyRequest, err = locksmith.PromptRekeyOptions()
+		if err != nil {
+			return err
+		}
+	}
+	rekeyRequest.KeyserverURL = opts.keyserver
+	rekeyRequest.Roster = roster
+
+	// Fetch and validate participant keys, and have the group confirm the fingerprints
+	fetcher := locksmith.KeyFetcher{KeyserverURL: rekeyRequest.KeyserverURL}
+	rekeyRequest.PGPKeys, err = fetcher.FetchPublicKeys(rekeyRequest.Participants)
+	if err != nil {
+		return locksmith.WrapError(err, "failed to fetch participant keys")
+	}
+	checks := locksmith.CheckPublicKeys(rekeyRequest.Participants, rekeyRequest.PGPKeys)
+	printKeyChecks(checks)
+	err = locksmith.KeyCheckErrors(checks)
+	if err != nil {
+		return err
+	}
+	if roster != nil {
+		err = roster.VerifyKeys(checks)
+		if err != nil {
+			return err
+		}
+		fmt.Println("📌 All fingerprints match the roster.")
+	}
+	// A ceremony file with every fingerprint pinned was already reviewed
+	if opts.ceremony == nil || roster == nil {
+		confirmed, err := locksmith.Confirm("Have all participants confirmed their fingerprints?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return errors.New("participant fingerprints were not confirmed")
+		}
+	}
+	status, err = client.StartRekey(rekeyRequest)
 	if err != nil {
 		return locksmith.WrapError(err, "failed to start rekey operation")
 	}
 
 	fmt.Printf("Rekey operation started. %d key shares must be provided.\n", status.Required)
 
-	// Wait for all other participants to submit their keys before prompting the leader
-	// This is to ensure the leader recieves the new keys generated by Vault
-	locksmith.WaitForParticipantRekeySubmissions(vaultURL)
+	state := locksmith.CeremonyState{
+		Phase:           locksmith.StartedPhase,
+		VaultURL:        client.URL(),
+		Namespace:       client.Namespace(),
+		KeyType:         client.KeyType(),
+		Nonce:           status.Nonce,
+		SecretShares:    rekeyRequest.SecretShares,
+		SecretThreshold: rekeyRequest.SecretThreshold,
+		Participants:    locksmith.ParticipantNames(rekeyRequest.Participants),
+		Identities:      keyIdentities(checks),
+		Fingerprints:    keyFingerprints(checks),
+		StartedAt:       startedAt,
+	}
+	// Vault has not returned any keys yet, so cancelling now loses nothing
+	err = stateFile.Save(state)
+	if err != nil {
+		cancelErr := client.CancelRekey()
+		if cancelErr != nil {
+			return locksmith.WrapError(err, "failed to save ceremony state and to cancel the rekey, run 'locksmith cancel' before starting a new one")
+		}
+		return locksmith.WrapError(err, "failed to save ceremony state, the rekey was cancelled")
+	}
 
-	// Prompt for leader's key & submit
-	// Retry until a valid key is submitted, or a unrecoverable error occurs
-	for {
-		status, err = locksmith.SubmitKey(vaultURL, locksmith.Prompt("Key share"))
+	return runLeaderCeremony(client, opts, output, stateFile, state)
+}
+
+// resumeLeaderTrack picks up an interrupted ceremony from the last phase saved to the state file.
+func resumeLeaderTrack(client *locksmith.Client, opts options, output leaderOutput) error {
+	stateFile, state, err := locksmith.OpenStateFile(opts.stateFile)
+	if err != nil {
+		return err
+	}
+	if state.VaultURL != client.URL() {
+		return fmt.Errorf("state file %s is for %s, not %s", opts.stateFile, state.VaultURL, client.URL())
+	}
+	if state.KeyType != client.KeyType() {
+		return fmt.Errorf("state file %s is for a %s rekey, not %s", opts.stateFile, state.KeyType, client.KeyType())
+	}
+	fmt.Printf("Resuming the rekey started at %s, %d shares, threshold %d.\n", state.StartedAt.Local().Format(time.RFC1123), state.SecretShares, state.SecretThreshold)
+
+	// The new keys are saved whatever Vault says now, since they may be the only copy
+	if state.Phase == locksmith.StartedPhase {
+		status, err := client.GetRekeyStatus()
 		if err != nil {
-			if status.InvalidKeysError() {
-				return errors.New("invalid keys submitted, please cancel rekey and try again")
+			return locksmith.WrapError(err, "failed to get rekey status")
+		}
+		if !status.InProgress() || status.Nonce != state.Nonce {
+			return errors.New("the rekey in the state file is no longer in progress, remove " + opts.stateFile + " and start a new one")
+		}
+		if status.VerificationNonce != "" {
+			return errors.New("vault returned the new keys before they were saved, run 'locksmith cancel' and start a new rekey")
+		}
+	}
+	return runLeaderCeremony(client, opts, output, stateFile, state)
+}
+
+// runLeaderCeremony runs the leader's ceremony from the phase in state, saving each completed phase.
+func runLeaderCeremony(client *locksmith.Client, opts options, output leaderOutput, stateFile *locksmith.StateFile, state locksmith.CeremonyState) error {
+	if state.Phase == locksmith.StartedPhase {
+		// Wait for all other participants to submit their keys before prompting the leader
+		// This is to ensure the leader recieves the new keys generated by Vault
+		client.WaitForParticipantRekeySubmissions()
+
+		// Prompt for leader's key & submit
+		// Retry until a valid key is submitted, or a unrecoverable error occurs
+		var status locksmith.RekeyStatus
+		for {
+			share, err := readKeyShare(opts)
+			if err != nil {
+				return err
 			}
-			printError(locksmith.WrapError(err, "failed to submit key"))
-			continue
+			status, err = client.SubmitKey(share)
+			if err != nil {
+				if status.InvalidKeysError() {
+					return errors.New("invalid keys submitted, run 'locksmith cancel' and try again")
+				}
+				if opts.share.Configured() {
+					return locksmith.WrapError(err, "failed to submit key")
+				}
+				printError(locksmith.WrapError(err, "failed to submit key"))
+				continue
+			}
+			if len(status.Keys) == 0 {
+				return errors.New("no keys returned from vault, run 'locksmith cancel' and try again")
+			}
+			break
+		}
+		state.ReceiveKeys(status)
+		saveState(stateFile, state)
+		shredShareFile(opts)
+	}
+
+	if state.Phase == locksmith.KeysReceivedPhase {
+		// Make sure Vault encrypted the new shares to the keys the group confirmed
+		// Verification has not completed, so cancelling keeps the current keys valid
+		err := verifyVaultFingerprints(state)
+		if err != nil {
+			return locksmith.WrapError(err, "vault returned unexpected fingerprints, run 'locksmith cancel' and investigate before retrying")
 		}
-		if len(status.Keys) == 0 {
-			return errors.New("no keys returned from vault, please cancel rekey and try again")
+
+		// Save new recovery keys to file
+		keysRequest := state.KeysRequest(opts.outputDir, output.format)
+		localSink := locksmith.LocalSink{Directory: opts.outputDir, Format: output.format}
+		keysFile, err := localSink.Store(client.URL(), keysRequest)
+		if err != nil {
+			// Vault will not return these keys again, so they must not be lost with the file
+			printError(locksmith.WrapError(err, "failed to generate key file"))
+			fmt.Println("⚠️  The new keys are printed below instead. Copy them somewhere safe before continuing.")
+			printProgressBar()
+			locksmith.PrintKeys(client.URL(), keysRequest)
+			printProgressBar()
 		}
-		break
+		stored := keysFile != ""
+		for _, sink := range output.sinks {
+			location, err := sink.Store(client.URL(), keysRequest)
+			if err != nil {
+				printError(locksmith.WrapError(err, "failed to store keys in "+sink.String()))
+				continue
+			}
+			fmt.Printf("✍️  New keys saved to: %s\n", location)
+			stored = true
+		}
+		// The state file stays in this phase, as the only copy of the keys on disk, until a sink holds them
+		if !stored {
+			return errors.New("the new keys could not be stored, fix the output and run 'locksmith leader -resume' to store them again")
+		}
+		state.Phase = locksmith.KeysSavedPhase
+		state.KeysFile = keysFile
+		saveState(stateFile, state)
 	}
 
-	// Save new recovery keys to file
-	err = locksmith.WriteKeysToFile(vaultURL, locksmith.WriteKeysToFileRequest{
-		KeybaseUsers:    rekeyRequest.KeybaseUsers,
-		PGPFingerprints: status.PGPFingerprints,
-		Keys:            status.Keys,
-		KeysBase64:      status.KeysBase64,
-	})
+	// Participants may have verified without the leader, or cancelled, while the leader was away
+	status, err := client.GetRekeyStatus()
 	if err != nil {
-		return locksmith.WrapError(err, "failed to generate key file")
+		return locksmith.WrapError(err, "failed to get rekey status")
+	}
+	if !status.InProgress() || status.Nonce != state.Nonce {
+		removeState(stateFile)
+		return errors.New("the rekey is no longer in progress, the new keys are valid only if the other participants completed verification")
 	}
 
 	fmt.Println("Verification has begun. Please wait for other participants to submit their keys.")
 
 	// Wait for all other participants to submit their verifications before prompting the leader
 	// This is to ensure the leader recieves the "complete" status from Vault
-	locksmith.WaitForParticipantVerificationSubmissions(vaultURL)
+	client.WaitForParticipantVerificationSubmissions()
 
 	// Submit verification key and validate response
-	finalStatus, err := locksmith.SubmitVerification(vaultURL, locksmith.Prompt("New key share"))
+	share, err := leaderNewShare(opts, state)
+	if err != nil {
+		return err
+	}
+	finalStatus, err := client.SubmitVerification(share)
 	if err != nil {
 		return locksmith.WrapError(err, "failed to submit final verification")
 	}
-	if !finalStatus.Completed() || finalStatus.HasError() {
-		return locksmith.WrapError(err, "rekey verification failed")
+	if finalStatus.HasError() {
+		return locksmith.WrapError(finalStatus.Error(), "rekey verification failed")
+	}
+	if !finalStatus.Completed() {
+		return errors.New("rekey verification did not complete")
 	}
 
 	fmt.Println("✅ Vault has been rekeyed, and new keys have been verified. Success!")
+	removeState(stateFile)
+
+	if output.signer != nil {
+		manifest, err := locksmith.NewManifest(client.URL(), state.KeysRequest(opts.outputDir, output.format))
+		if err != nil {
+			return locksmith.WrapError(err, "vault has been rekeyed, but the manifest could not be created")
+		}
+		manifest.ClusterName = opts.cluster.Health.ClusterName
+		manifest.ClusterID = opts.cluster.Health.ClusterID
+		manifest.VerificationNonce = state.VerificationNonce
+		manifest.StartedAt = state.StartedAt
+		manifest.FinishedAt = time.Now().UTC()
+		manifestFile, err := locksmith.WriteManifest(manifest, output.signer, state.KeysFile, opts.outputDir)
+		if err != nil {
+			return locksmith.WrapError(err, "vault has been rekeyed, but the manifest could not be saved")
+		}
+		fmt.Printf("🔏 Signed manifest saved to: %s\n", manifestFile)
+	}
 
 	return nil
 }
 
-func executeFollowerTrack(vaultURL string) error {
+// loadLeaderOutput parses the output options, and loads the signing key up front
+// so a wrong passphrase does not surface after the rekey.
+func loadLeaderOutput(opts options) (leaderOutput, error) {
+	var output leaderOutput
+	var err error
+	output.format, err = locksmith.ParseKeyFileFormat(opts.outputFormat)
+	if err != nil {
+		return leaderOutput{}, err
+	}
+
+	if opts.signingKey != "" {
+		output.signer, err = locksmith.LoadManifestSigner(opts.signingKey)
+		if err != nil {
+			return leaderOutput{}, err
+		}
+		fmt.Printf("The ceremony manifest will be signed with %s.\n", output.signer)
+	}
+
+	// The local key file is always written, so the leader holds a copy whatever happens to the other sinks
+	for _, spec := range opts.sinks {
+		sink, err := locksmith.ParseKeySink(spec, output.format)
+		if err != nil {
+			return leaderOutput{}, err
+		}
+		output.sinks = append(output.sinks, sink)
+	}
+	return output, nil
+}
+
+// saveState records a completed phase. The ceremony carries on if the state file cannot be written,
+// since stopping would not bring the new keys back.
+func saveState(stateFile *locksmith.StateFile, state locksmith.CeremonyState) {
+	err := stateFile.Save(state)
+	if err != nil {
+		printError(err)
+		fmt.Println("⚠️  The ceremony cannot be resumed if locksmith is interrupted.")
+	}
+}
+
+func removeState(stateFile *locksmith.StateFile) {
+	err := stateFile.Remove()
+	if err != nil {
+		printError(locksmith.WrapError(err, "failed to remove state file "+stateFile.Path))
+	}
+}
+
+func executeFollowerTrack(client *locksmith.Client, opts options) error {
 	// Check for existing rekey operation
-	status, err := locksmith.GetRekeyStatus(vaultURL)
+	status, err := client.GetRekeyStatus()
 	if err != nil {
 		return locksmith.WrapError(err, "failed to get rekey status")
 	}
 
 	if !status.InProgress() {
-		locksmith.WaitForRekeyStart(vaultURL)
+		client.WaitForRekeyStart()
 	} else {
 		fmt.Println("A rekey operation is in-progress. Please enter your key share.")
 	}
@@ -130,25 +581,41 @@ func executeFollowerTrack(vaultURL string) error {
 	// Prompt for user's key & submit
 	// Retry until a valid key is submitted
 	for {
-		_, err = locksmith.SubmitKey(vaultURL, locksmith.Prompt("Key share"))
+		share, err := readKeyShare(opts)
+		if err != nil {
+			return err
+		}
+		_, err = client.SubmitKey(share)
 		if err != nil {
+			if opts.share.Configured() {
+				return locksmith.WrapError(err, "failed to submit key")
+			}
 			printError(locksmith.WrapError(err, "failed to submit key"))
 			continue
 		}
 		break
 	}
+	shredShareFile(opts)
 
 	fmt.Println("Key submitted successfully. Waiting for other participants to submit their keys.")
 
-	locksmith.WaitForRekeyCompletion(vaultURL)
+	client.WaitForRekeyCompletion()
 
 	fmt.Println("Verification has begun. Please enter your new key share to verify.")
 
 	// Prompt for a user's verification & submit
 	// Retry until a valid verification is submitted
 	for {
-		_, err = locksmith.SubmitVerification(vaultURL, locksmith.Prompt("New key share"))
+		share, err := promptNewShare(opts)
 		if err != nil {
+			return err
+		}
+		_, err = client.SubmitVerification(share)
+		if err != nil {
+			// A share read from a key file would be the same on every attempt
+			if opts.keysFile != "" {
+				return locksmith.WrapError(err, "failed to submit verification")
+			}
 			printError(locksmith.WrapError(err, "failed to submit verification"))
 			continue
 		}
@@ -157,21 +624,381 @@ func executeFollowerTrack(vaultURL string) error {
 
 	fmt.Println("Key verification submitted successfully. Waiting for other participants to submit their keys.")
 
-	locksmith.WaitForVerificationCompletion(vaultURL)
+	client.WaitForVerificationCompletion()
 
 	fmt.Println("☑️  Operation complete. Any potential errors will be returned to the leader.")
 
 	return nil
 }
 
-func validArgs(args []string) bool {
-	if len(args) != 2 {
-		return false
+func executeCancel(client *locksmith.Client, opts options) error {
+	// Check for existing rekey operation
+	status, err := client.GetRekeyStatus()
+	if err != nil {
+		return locksmith.WrapError(err, "failed to get rekey status")
+	}
+	if !status.InProgress() {
+		fmt.Println("No rekey operation in progress. Nothing to cancel.")
+		return nil
+	}
+
+	if status.VerificationNonce != "" {
+		fmt.Println("A rekey operation is in the verification phase.")
+	} else {
+		fmt.Printf("A rekey operation is in progress. %d/%d shares provided.\n", status.Progress, status.Required)
+	}
+
+	if opts.verificationOnly {
+		if status.VerificationNonce == "" {
+			return errors.New("verification has not started, nothing to restart")
+		}
+		if !opts.yes {
+			confirmed, err := locksmith.Confirm("Restart verification? Participants must submit their new key shares again")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Aborted.")
+				return nil
+			}
+		}
+		_, err = client.RestartVerification()
+		if err != nil {
+			return locksmith.WrapError(err, "failed to restart verification")
+		}
+		fmt.Println("✅ Verification restarted. Participants may now submit their new key shares.")
+		return nil
+	}
+
+	if !opts.yes {
+		confirmed, err := locksmith.Confirm("Cancel the rekey operation? Current keys will remain valid")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+	err = client.CancelRekey()
+	if err != nil {
+		return locksmith.WrapError(err, "failed to cancel rekey operation")
+	}
+	fmt.Println("✅ Rekey operation cancelled. Current keys remain valid.")
+	return nil
+}
+
+func executeVerifyManifest(path string, opts options) error {
+	if opts.publicKeyFile == "" {
+		return errors.New("-public-key is required to verify the manifest signature")
+	}
+	if opts.signatureFile == "" {
+		opts.signatureFile = path + ".sig"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return locksmith.WrapError(err, "failed to read manifest")
+	}
+	signature, err := os.ReadFile(opts.signatureFile)
+	if err != nil {
+		return locksmith.WrapError(err, "failed to read manifest signature")
+	}
+	publicKeys, err := os.ReadFile(opts.publicKeyFile)
+	if err != nil {
+		return locksmith.WrapError(err, "failed to read public key file")
+	}
+
+	signer, err := locksmith.VerifyManifestSignature(data, signature, publicKeys)
+	if err != nil {
+		return err
+	}
+	manifest, err := locksmith.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔏 Manifest signature is valid, signed by %s.\n", signer)
+	printManifest(manifest)
+
+	if opts.keysFile == "" {
+		fmt.Println("⚠️  No -keys-file given, the encrypted shares were not checked against the manifest.")
+		return nil
+	}
+	checks, err := manifest.VerifyShares(opts.keysFile)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, check := range checks {
+		if check.Err != nil {
+			fmt.Printf("🚫 %s: %s\n", check.Participant, check.Err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ %s: encrypted share matches\n", check.Participant)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d encrypted shares do not match the manifest", failed, len(checks))
+	}
+	fmt.Println("✅ Encrypted shares match the manifest.")
+	return nil
+}
+
+func executeScan(images []string, opts options) error {
+	share, err := locksmith.ScanShare(images)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📷 Reassembled an encrypted %s key share from %d QR codes.\n", share.KeyType, share.Parts)
+	fmt.Printf("SHA-256: %s\n", share.SHA256)
+
+	output := opts.scanOutput
+	if output == "" {
+		output = fmt.Sprintf("%s-share-%s.asc", share.KeyType, share.SHA256[:16])
+	}
+	err = share.Save(output)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✍️  Encrypted key share saved to: %s\n", output)
+	fmt.Printf("Check the SHA-256 against the printed page, then decrypt it with gpg --decrypt %s\n", output)
+	return nil
+}
+
+func printManifest(manifest locksmith.Manifest) {
+	fmt.Printf("Vault URL: %s\n", manifest.VaultURL)
+	if manifest.ClusterName != "" {
+		fmt.Printf("Cluster:   %s (%s)\n", manifest.ClusterName, manifest.ClusterID)
+	}
+	if manifest.Namespace != "" {
+		fmt.Printf("Namespace: %s\n", manifest.Namespace)
+	}
+	fmt.Printf("Key type:  %s, %d shares, threshold %d\n", manifest.KeyType, manifest.SecretShares, manifest.SecretThreshold)
+	fmt.Printf("Nonce:     %s\n", manifest.Nonce)
+	fmt.Printf("Started:   %s\n", manifest.StartedAt.Format(time.RFC3339))
+	fmt.Printf("Finished:  %s\n", manifest.FinishedAt.Format(time.RFC3339))
+	fmt.Println("Participants:")
+	for _, participant := range manifest.Participants {
+		fmt.Printf("   %s\n", participant.Name)
+		fmt.Printf("      Fingerprint: %s\n", formatFingerprint(participant.Fingerprint))
+		fmt.Printf("      SHA-256:     %s\n", participant.EncryptedKeySHA256)
+	}
+}
+
+// applyCeremony fills in options from a ceremony file. Flags given on the command line take precedence.
+func applyCeremony(flags *flag.FlagSet, opts *options, ceremony locksmith.Ceremony) {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["namespace"] && ceremony.Namespace != "" {
+		opts.namespace = ceremony.Namespace
+	}
+	if !set["key-type"] && ceremony.KeyType != "" {
+		opts.keyType = ceremony.KeyType
+	}
+	if !set["keyserver"] && ceremony.Keyserver != "" {
+		opts.keyserver = ceremony.Keyserver
+	}
+	if !set["roster"] && ceremony.Roster != "" {
+		opts.rosterFile = ceremony.Roster
+	}
+	if !set["output-format"] && ceremony.Output.Format != "" {
+		opts.outputFormat = ceremony.Output.Format
+	}
+	if !set["sink"] {
+		opts.sinks = ceremony.Output.Sinks
+	}
+	if !set["output-dir"] && ceremony.Output.Directory != "" {
+		opts.outputDir = ceremony.Output.Directory
+	}
+	if !set["signing-key"] && ceremony.Output.SigningKey != "" {
+		opts.signingKey = ceremony.Output.SigningKey
+	}
+	opts.ceremony = &ceremony
+}
+
+func newClient(vaultURL string, opts options) (*locksmith.Client, error) {
+	token, err := locksmith.LoadToken(opts.tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	keyType, err := locksmith.ParseKeyType(opts.keyType)
+	if err != nil {
+		return nil, err
+	}
+	return locksmith.NewClient(
+		vaultURL,
+		locksmith.WithToken(token),
+		locksmith.WithNamespace(opts.namespace),
+		locksmith.WithTLSConfig(opts.tls),
+		locksmith.WithKeyType(keyType),
+	)
+}
+
+// readKeyShare returns the participant's current key share, from the configured source or a prompt.
+func readKeyShare(opts options) (string, error) {
+	if !opts.share.Configured() {
+		return locksmith.PromptShare("Key share", opts.mask)
+	}
+	share, err := opts.share.Read()
+	if err != nil {
+		return "", err
+	}
+	for _, warning := range share.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	fmt.Printf("Read %s key share from %s.\n", share.Encoding, opts.share.Describe())
+	return share.Value, nil
+}
+
+func shredShareFile(opts options) {
+	if !opts.share.Shred {
+		return
+	}
+	err := opts.share.Done()
+	if err != nil {
+		printError(locksmith.WrapError(err, "failed to shred key share file"))
+		return
+	}
+	fmt.Printf("🗑️  Key share file %s shredded.\n", opts.share.File)
+}
+
+// promptNewShare returns the participant's new key share for verification. With -decrypt,
+// the encrypted share is decrypted locally so the plaintext is never pasted.
+func promptNewShare(opts options) (string, error) {
+	if !opts.decrypt {
+		return locksmith.PromptShare("New key share", opts.mask)
+	}
+	decrypter := locksmith.ShareDecrypter{PrivateKeyFile: opts.privateKey}
+	if opts.share.Configured() && opts.keysFile == "" {
+		return "", errors.New("no key file to read the new key share from, verification needs it when running unattended")
+	}
+
+	if opts.keysFile != "" {
+		encrypted, err := locksmith.ReadEncryptedShare(opts.keysFile, opts.participant)
+		if err != nil {
+			return "", err
+		}
+		fmt.Printf("Decrypting the new key share for %s from %s.\n", opts.participant, opts.keysFile)
+		share, err := decrypter.Decrypt(encrypted)
+		if err != nil {
+			return "", locksmith.WrapError(err, "failed to decrypt new key share")
+		}
+		return share, nil
+	}
+
+	// Retry until the pasted share decrypts
+	for {
+		encrypted, err := locksmith.PromptEncryptedShare("Encrypted new key share")
+		if err != nil {
+			return "", err
+		}
+		share, err := decrypter.Decrypt(encrypted)
+		if errors.Is(err, locksmith.ErrInputClosed) || errors.Is(err, locksmith.ErrInterrupted) {
+			return "", err
+		}
+		if err != nil {
+			printError(locksmith.WrapError(err, "failed to decrypt new key share"))
+			continue
+		}
+		return share, nil
+	}
+}
+
+// leaderNewShare decrypts the leader's new key share from the ceremony state when -participant is
+// given, which holds it whichever sinks stored the keys, and otherwise prompts for it.
+func leaderNewShare(opts options, state locksmith.CeremonyState) (string, error) {
+	if !opts.decrypt || opts.keysFile != "" || opts.participant == "" {
+		return promptNewShare(opts)
+	}
+	encrypted, err := state.EncryptedShare(opts.participant)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Decrypting the new key share for %s.\n", opts.participant)
+	share, err := locksmith.ShareDecrypter{PrivateKeyFile: opts.privateKey}.Decrypt(encrypted)
+	if err != nil {
+		return "", locksmith.WrapError(err, "failed to decrypt new key share")
+	}
+	return share, nil
+}
+
+// stringList collects the values of a repeated flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func validRole(role string) bool {
+	return role == leaderRole || role == followerRole || role == cancelRole || role == verifyManifestRole || role == scanRole
+}
+
+// verifyVaultFingerprints compares the fingerprints Vault encrypted the new keys to against the
+// fingerprints the group confirmed, which were checked against any roster before the rekey started.
+func verifyVaultFingerprints(state locksmith.CeremonyState) error {
+	confirmed := locksmith.Roster{}
+	var participants []locksmith.Participant
+	for i, name := range state.Participants {
+		confirmed[name] = state.Fingerprints[i]
+		participants = append(participants, locksmith.Participant{Name: name})
+	}
+	return confirmed.VerifyFingerprints(participants, state.PGPFingerprints)
+}
+
+func keyIdentities(checks []locksmith.KeyCheck) []string {
+	var identities []string
+	for _, check := range checks {
+		identities = append(identities, check.Identity)
+	}
+	return identities
+}
+
+func keyFingerprints(checks []locksmith.KeyCheck) []string {
+	var fingerprints []string
+	for _, check := range checks {
+		fingerprints = append(fingerprints, check.Fingerprint)
 	}
-	if !(args[0] == leaderRole || args[0] == followerRole) {
-		return false
+	return fingerprints
+}
+
+func printKeyChecks(checks []locksmith.KeyCheck) {
+	fmt.Println("Participant keys:")
+	for _, check := range checks {
+		icon := "✅"
+		if !check.OK() {
+			icon = "🚫"
+		}
+		fmt.Printf("%s %s\n", icon, check.Participant.Name)
+		if check.Fingerprint != "" {
+			fmt.Printf("   Fingerprint: %s\n", formatFingerprint(check.Fingerprint))
+			fmt.Printf("   Identity:    %s\n", check.Identity)
+			fmt.Printf("   Algorithm:   %s\n", check.Algorithm)
+		}
+		for _, warning := range check.Warnings {
+			fmt.Printf("   ⚠️  %s\n", warning)
+		}
+		for _, err := range check.Errors {
+			fmt.Printf("   🚫 %s\n", err)
+		}
+	}
+}
+
+// formatFingerprint groups a fingerprint into blocks of four, as gpg displays it.
+func formatFingerprint(fingerprint string) string {
+	var blocks []string
+	fingerprint = strings.ToUpper(fingerprint)
+	for len(fingerprint) > 4 {
+		blocks = append(blocks, fingerprint[:4])
+		fingerprint = fingerprint[4:]
 	}
-	return true
+	blocks = append(blocks, fingerprint)
+	return strings.Join(blocks, " ")
 }
 
 func printError(err error) {
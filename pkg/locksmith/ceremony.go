@@ -0,0 +1,305 @@
+package locksmith
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// Ceremony defines a rekey ahead of time, so that it can be reviewed like any
+// other change before the leader runs it. Files may be YAML, JSON or HCL, e.g.
+//
+//	vault_url        = "https://vault.example.com:8200"
+//	key_type         = "recovery"
+//	secret_shares    = 3
+//	secret_threshold = 2
+//
+//	participant "alice" {
+//	  key         = "keybase:alice"
+//	  fingerprint = "6756 731D BEE8 ..."
+//	}
+//
+//	output {
+//	  directory = "keys"
+//	  format    = "json"
+//	  sinks     = ["s3://ceremony-keys/vault?endpoint=https://minio.example.com"]
+//	}
+//
+// Relative paths are resolved against the directory of the ceremony file.
+type Ceremony struct {
+	VaultURL        string                `json:"vault_url" yaml:"vault_url" hcl:"vault_url"`
+	Namespace       string                `json:"namespace" yaml:"namespace" hcl:"namespace"`
+	KeyType         string                `json:"key_type" yaml:"key_type" hcl:"key_type"`
+	SecretShares    int                   `json:"secret_shares" yaml:"secret_shares" hcl:"secret_shares"`
+	SecretThreshold int                   `json:"secret_threshold" yaml:"secret_threshold" hcl:"secret_threshold"`
+	Keyserver       string                `json:"keyserver" yaml:"keyserver" hcl:"keyserver"`
+	Roster          string                `json:"roster" yaml:"roster" hcl:"roster"`
+	Participants    []CeremonyParticipant `json:"participants" yaml:"participants" hcl:"participant"`
+	Output          CeremonyOutput        `json:"output" yaml:"output" hcl:"output"`
+}
+
+// CeremonyParticipant is a participant in a ceremony file. Key takes the same
+// source specs as the interactive prompt, and Fingerprint optionally pins it.
+type CeremonyParticipant struct {
+	Name        string `json:"name" yaml:"name" hcl:",key"`
+	Key         string `json:"key" yaml:"key" hcl:"key"`
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint" hcl:"fingerprint"`
+}
+
+type CeremonyOutput struct {
+	Directory string `json:"directory" yaml:"directory" hcl:"directory"`
+	Format    string `json:"format" yaml:"format" hcl:"format"`
+	// Sinks are additional places to store the keys, as accepted by ParseKeySink
+	Sinks []string `json:"sinks" yaml:"sinks" hcl:"sinks"`
+	// SigningKey is the leader's PGP or OpenSSH private key, used to sign the ceremony manifest
+	SigningKey string `json:"signing_key" yaml:"signing_key" hcl:"signing_key"`
+}
+
+func LoadCeremony(path string) (Ceremony, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Ceremony{}, WrapError(err, "failed to read ceremony file")
+	}
+
+	var ceremony Ceremony
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&ceremony)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&ceremony)
+	case ".hcl":
+		err = decodeHCLCeremony(contents, &ceremony)
+	default:
+		return Ceremony{}, errors.New("ceremony file must be .yaml, .yml, .json or .hcl: " + path)
+	}
+	if err != nil {
+		return Ceremony{}, WrapError(err, "failed to parse ceremony file")
+	}
+
+	// Paths in the file are relative to the file, not to where locksmith runs
+	dir := filepath.Dir(path)
+	ceremony.Roster = resolvePath(dir, ceremony.Roster)
+	ceremony.Output.Directory = resolvePath(dir, ceremony.Output.Directory)
+	ceremony.Output.SigningKey = resolvePath(dir, ceremony.Output.SigningKey)
+	for i, spec := range ceremony.Output.Sinks {
+		ceremony.Output.Sinks[i] = resolveSinkPaths(dir, spec)
+	}
+	for i, participant := range ceremony.Participants {
+		parsed, err := ParseParticipant(participant.Key)
+		if err == nil && parsed.Source == FileSource {
+			ceremony.Participants[i].Key = string(FileSource) + ":" + resolvePath(dir, parsed.Value)
+		}
+	}
+
+	err = ceremony.Validate()
+	if err != nil {
+		return Ceremony{}, WrapError(err, "invalid ceremony file")
+	}
+	return ceremony, nil
+}
+
+// decodeHCLCeremony decodes an HCL ceremony file, rejecting unknown keys as the
+// YAML and JSON decoders do, so a misspelled key is not silently ignored.
+func decodeHCLCeremony(contents []byte, ceremony *Ceremony) error {
+	file, err := hcl.ParseBytes(contents)
+	if err != nil {
+		return err
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return errors.New("ceremony file does not contain a root object")
+	}
+	err = checkHCLKeys(list, "vault_url", "namespace", "key_type", "secret_shares", "secret_threshold", "keyserver", "roster", "participant", "output")
+	if err != nil {
+		return err
+	}
+	for _, item := range list.Filter("participant").Items {
+		err = checkHCLKeys(item.Val, "key", "fingerprint")
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range list.Filter("output").Items {
+		err = checkHCLKeys(item.Val, "directory", "format", "sinks", "signing_key")
+		if err != nil {
+			return err
+		}
+	}
+	return hcl.DecodeObject(ceremony, file.Node)
+}
+
+func checkHCLKeys(node ast.Node, allowed ...string) error {
+	var list *ast.ObjectList
+	switch node := node.(type) {
+	case *ast.ObjectList:
+		list = node
+	case *ast.ObjectType:
+		list = node.List
+	default:
+		return nil
+	}
+	for _, item := range list.Items {
+		key := strings.Trim(item.Keys[0].Token.Text, `"`)
+		known := false
+		for _, name := range allowed {
+			if key == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown key %s on line %d", key, item.Keys[0].Pos().Line)
+		}
+	}
+	return nil
+}
+
+func (c Ceremony) Validate() error {
+	if len(c.Participants) == 0 {
+		return errors.New("no participants defined")
+	}
+	if c.SecretShares != len(c.Participants) {
+		return fmt.Errorf("secret_shares is %d, but %d participants are defined", c.SecretShares, len(c.Participants))
+	}
+	err := ValidateSecretShares(c.SecretShares, c.SecretThreshold)
+	if err != nil {
+		return err
+	}
+	if c.KeyType != "" {
+		_, err = ParseKeyType(c.KeyType)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = ParseKeyFileFormat(c.Output.Format)
+	if err != nil {
+		return err
+	}
+	for _, spec := range c.Output.Sinks {
+		err = ValidateKeySink(spec)
+		if err != nil {
+			return err
+		}
+	}
+	participants, err := c.parseParticipants()
+	if err != nil {
+		return err
+	}
+
+	// A roster must pin every participant, so partial pins need a roster file to fill the gaps
+	pins := c.Pins()
+	if pins != nil && c.Roster == "" {
+		for _, participant := range participants {
+			if _, ok := pins.Fingerprint(participant); !ok {
+				return errors.New("participant " + participant.Name + " has no fingerprint, pin every participant or none")
+			}
+		}
+	}
+	return nil
+}
+
+// RekeyRequest builds the request the leader would otherwise enter at the prompts.
+func (c Ceremony) RekeyRequest() (StartRekeyRequest, error) {
+	participants, err := c.parseParticipants()
+	if err != nil {
+		return StartRekeyRequest{}, err
+	}
+	return StartRekeyRequest{
+		SecretShares:    c.SecretShares,
+		SecretThreshold: c.SecretThreshold,
+		Participants:    participants,
+		KeyserverURL:    c.Keyserver,
+	}, nil
+}
+
+// Pins returns the fingerprints pinned in the ceremony file, or nil if there are none.
+func (c Ceremony) Pins() Roster {
+	participants, err := c.parseParticipants()
+	if err != nil {
+		return nil
+	}
+	var roster Roster
+	for i, participant := range participants {
+		fingerprint := c.Participants[i].Fingerprint
+		if fingerprint == "" {
+			continue
+		}
+		if roster == nil {
+			roster = Roster{}
+		}
+		roster[participant.Name] = normalizeFingerprint(fingerprint)
+	}
+	return roster
+}
+
+func (c Ceremony) parseParticipants() ([]Participant, error) {
+	var participants []Participant
+	for i, participant := range c.Participants {
+		if participant.Key == "" {
+			return nil, fmt.Errorf("participant %d has no key", i+1)
+		}
+		spec := participant.Key
+		if participant.Name != "" {
+			spec = participant.Name + "=" + spec
+		}
+		parsed, err := ParseParticipant(spec)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, parsed)
+	}
+	err := validateUniqueParticipants(participants)
+	if err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
+
+// resolveSinkPaths resolves the local paths in a sink spec: the directory of a file sink,
+// and the token_file and ca_cert options of a vault-kv sink.
+func resolveSinkPaths(dir string, spec string) string {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "file:") {
+		directory := strings.TrimPrefix(spec, "file:")
+		if directory == "" {
+			directory = "."
+		}
+		return "file:" + resolvePath(dir, directory)
+	}
+	if !strings.HasPrefix(spec, "vault-kv://") {
+		return spec
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		// Left for Validate to report
+		return spec
+	}
+	query := u.Query()
+	for _, option := range []string{"token_file", "ca_cert"} {
+		if query.Get(option) != "" {
+			query.Set(option, resolvePath(dir, query.Get(option)))
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func resolvePath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,213 @@
+package locksmith
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout   = 30 * time.Second
+	defaultUserAgent = "locksmith"
+)
+
+// Client executes rekey operations against a single Vault cluster.
+// A Client is safe to share between the ceremony and the wait loops.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	namespace  string
+	timeout    time.Duration
+	userAgent  string
+	tlsConfig  *tls.Config
+	keyType    KeyType
+}
+
+// ClientOption configures a Client during NewClient.
+type ClientOption func(*Client) error
+
+func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
+	baseURL, err := normalizeURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		baseURL:   baseURL,
+		timeout:   defaultTimeout,
+		userAgent: defaultUserAgent,
+		keyType:   RecoveryKeyType,
+	}
+	for _, opt := range opts {
+		if err := opt(client); err != nil {
+			return nil, WrapError(err, "failed to configure client")
+		}
+	}
+
+	// Copy the HTTP client so the timeout never leaks into a caller's client
+	httpClient := &http.Client{}
+	if client.httpClient != nil {
+		copied := *client.httpClient
+		httpClient = &copied
+	}
+	if client.timeout > 0 {
+		httpClient.Timeout = client.timeout
+	}
+	if client.tlsConfig != nil {
+		transport, err := transportWithTLS(httpClient.Transport, client.tlsConfig)
+		if err != nil {
+			return nil, WrapError(err, "failed to configure client")
+		}
+		httpClient.Transport = transport
+	}
+	client.httpClient = httpClient
+
+	return client, nil
+}
+
+// normalizeURL validates a Vault address and strips trailing slashes,
+// so that paths such as "/v1/sys/..." can be appended directly.
+func normalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("vault url is required")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", WrapError(err, "invalid vault url")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", errors.New("invalid vault url, scheme must be http or https: " + raw)
+	}
+	if parsed.Host == "" {
+		return "", errors.New("invalid vault url, missing host: " + raw)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", errors.New("invalid vault url, query strings and fragments are not supported: " + raw)
+	}
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// transportWithTLS clones the given transport, or the default transport when nil, with the TLS config applied.
+func transportWithTLS(base http.RoundTripper, tlsConfig *tls.Config) (*http.Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return nil, errors.New("tls settings require the http client to use an *http.Transport")
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("http client must not be nil")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+func WithToken(token string) ClientOption {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace sent with every request.
+func WithNamespace(namespace string) ClientOption {
+	return func(c *Client) error {
+		c.namespace = strings.Trim(strings.TrimSpace(namespace), "/")
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of each HTTP request. Zero disables the timeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		if timeout < 0 {
+			return errors.New("timeout must not be negative")
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) error {
+		c.userAgent = userAgent
+		return nil
+	}
+}
+
+func (c *Client) URL() string {
+	return c.baseURL
+}
+
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// newRequest builds a request against the Vault API, encoding body as JSON when present.
+func (c *Client) newRequest(method string, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewBuffer(encoded)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	return req, nil
+}
+
+// do executes a request, converting 403 responses into a descriptive error.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		defer resp.Body.Close()
+		return nil, c.permissionDeniedError(req, resp)
+	}
+	return resp, nil
+}
+
+func (c *Client) permissionDeniedError(req *http.Request, resp *http.Response) error {
+	err := decodeResponseError(resp)
+
+	if c.token == "" {
+		return WrapError(err, "vault denied access to "+req.URL.Path+" and no token was provided, set VAULT_TOKEN or use -token-file")
+	}
+	return WrapError(err, "vault denied access to "+req.URL.Path+", check that the token is valid and its policy grants access to this path")
+}
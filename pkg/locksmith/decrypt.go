@@ -0,0 +1,263 @@
+package locksmith
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/keybase/go-crypto/openpgp"
+	"github.com/keybase/go-crypto/openpgp/armor"
+	pgperrors "github.com/keybase/go-crypto/openpgp/errors"
+)
+
+const maxPassphraseAttempts = 3
+
+// ShareDecrypter decrypts a new key share that Vault encrypted to a participant's PGP key.
+// Shares are decrypted with gpg, and so gpg-agent, unless a private key file is given.
+type ShareDecrypter struct {
+	PrivateKeyFile string
+	GPGBinary      string
+}
+
+// Decrypt accepts an encrypted share as hex (ENCRYPTED_KEY), base64 (ENCRYPTED_KEY_BASE64)
+// or an ASCII-armored PGP message, and returns the plaintext share.
+func (d ShareDecrypter) Decrypt(encrypted string) (string, error) {
+	message, err := decodeEncryptedShare(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	var plaintext []byte
+	if d.PrivateKeyFile != "" {
+		plaintext, err = d.decryptWithKeyFile(message)
+	} else {
+		plaintext, err = d.decryptWithGPG(message)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Vault encrypts the hex-encoded share
+	share, err := ValidateShare(string(plaintext))
+	if err != nil {
+		return "", WrapError(err, "decrypted data is not a key share")
+	}
+	return share.Value, nil
+}
+
+func (d ShareDecrypter) decryptWithGPG(message []byte) ([]byte, error) {
+	binary := d.GPGBinary
+	if binary == "" {
+		binary = "gpg"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, errors.New(binary + " not found, install GnuPG or decrypt with a private key file")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "--quiet", "--decrypt")
+	cmd.Stdin = bytes.NewReader(message)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+		return nil, errors.New("gpg failed to decrypt the key share: " + detail)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (d ShareDecrypter) decryptWithKeyFile(message []byte) ([]byte, error) {
+	contents, err := os.ReadFile(d.PrivateKeyFile)
+	if err != nil {
+		return nil, WrapError(err, "failed to read private key file")
+	}
+	var keyring openpgp.EntityList
+	if block, err := armor.Decode(bytes.NewReader(contents)); err == nil {
+		keyring, err = openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, WrapError(err, "failed to parse private key file")
+		}
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(contents))
+		if err != nil {
+			return nil, WrapError(err, "failed to parse private key file")
+		}
+	}
+
+	attempts := 0
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if symmetric {
+			return nil, errors.New("key share is not encrypted to a public key")
+		}
+		if attempts == maxPassphraseAttempts {
+			return nil, errors.New("incorrect private key passphrase")
+		}
+		attempts++
+		input, err := PromptSecret("Private key passphrase", false)
+		if err != nil {
+			return nil, err
+		}
+		passphrase := []byte(input)
+		for _, key := range keys {
+			key.PrivateKey.Decrypt(passphrase)
+		}
+		return nil, nil
+	}
+	details, err := openpgp.ReadMessage(bytes.NewReader(message), keyring, prompt, nil)
+	if err != nil {
+		if err == pgperrors.ErrKeyIncorrect {
+			return nil, errors.New("the private key file does not match the key this share was encrypted to")
+		}
+		return nil, WrapError(err, "failed to decrypt the key share")
+	}
+	plaintext, err := io.ReadAll(details.UnverifiedBody)
+	if err != nil {
+		return nil, WrapError(err, "failed to decrypt the key share")
+	}
+	return plaintext, nil
+}
+
+// ReadEncryptedShare reads a participant's encrypted share from a key file written by WriteKeysToFile.
+// The path may also be a directory of armored shares, or a single armored share.
+func ReadEncryptedShare(path string, participant string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", WrapError(err, "failed to open key file")
+	}
+	if info.IsDir() {
+		return readEncryptedShareDir(path, participant)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return readEncryptedShareJSON(path, participant)
+	case ".asc":
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", WrapError(err, "failed to read key file")
+		}
+		return string(contents), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", WrapError(err, "failed to open key file")
+	}
+	defer file.Close()
+
+	var names []string
+	var current string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "PARTICIPANT: ") {
+			current = strings.TrimPrefix(line, "PARTICIPANT: ")
+			names = append(names, current)
+		}
+		if current == participant && strings.HasPrefix(line, "ENCRYPTED_KEY_BASE64: ") {
+			return strings.TrimPrefix(line, "ENCRYPTED_KEY_BASE64: "), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", WrapError(err, "failed to read key file")
+	}
+	if len(names) == 0 {
+		return "", errors.New("no participants found in key file: " + path)
+	}
+	return "", fmt.Errorf("participant %s not found in key file, expected one of: %s", participant, strings.Join(names, ", "))
+}
+
+func readEncryptedShareDir(dir string, participant string) (string, error) {
+	name := strings.TrimSuffix(shareFileName(participant, ""), "-.asc")
+	matches, err := filepath.Glob(filepath.Join(dir, "*.asc"))
+	if err != nil {
+		return "", WrapError(err, "failed to list key files")
+	}
+	var names []string
+	for _, match := range matches {
+		base := filepath.Base(match)
+		if dash := strings.LastIndex(base, "-"); dash > 0 && base[:dash] == name {
+			return ReadEncryptedShare(match, participant)
+		}
+		names = append(names, filepath.Base(match))
+	}
+	if len(names) == 0 {
+		return "", errors.New("no armored key shares found in: " + dir)
+	}
+	return "", fmt.Errorf("no key share for participant %s in %s, found: %s", participant, dir, strings.Join(names, ", "))
+}
+
+func readEncryptedShareJSON(path string, participant string) (string, error) {
+	keyFile, err := ReadKeyFile(path)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, p := range keyFile.Participants {
+		if p.Name == participant {
+			return p.EncryptedKeyBase64, nil
+		}
+		names = append(names, p.Name)
+	}
+	if len(names) == 0 {
+		return "", errors.New("no participants found in key file: " + path)
+	}
+	return "", fmt.Errorf("participant %s not found in key file, expected one of: %s", participant, strings.Join(names, ", "))
+}
+
+// PromptEncryptedShare prompts for an encrypted share on a single line, or as an
+// ASCII-armored message ending with its END line.
+func PromptEncryptedShare(prompt string) (string, error) {
+	input, err := promptString(prompt)
+	if err != nil || !strings.HasPrefix(input, "-----BEGIN PGP MESSAGE-----") {
+		return input, err
+	}
+	lines := []string{input}
+	for !strings.HasPrefix(input, "-----END PGP MESSAGE-----") {
+		line, err := readLine()
+		if err != nil {
+			return "", err
+		}
+		input = strings.TrimSpace(line)
+		lines = append(lines, input)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func decodeEncryptedShare(encrypted string) ([]byte, error) {
+	encrypted = strings.TrimSpace(encrypted)
+	if strings.HasPrefix(encrypted, "-----BEGIN PGP MESSAGE-----") {
+		block, err := armor.Decode(strings.NewReader(encrypted))
+		if err != nil {
+			return nil, WrapError(err, "invalid armored key share")
+		}
+		return io.ReadAll(block.Body)
+	}
+
+	compact := strings.Join(strings.Fields(encrypted), "")
+	if isHex(compact) {
+		message, err := hex.DecodeString(compact)
+		if err != nil {
+			return nil, WrapError(err, "invalid hex encrypted key share")
+		}
+		return message, nil
+	}
+	message, err := base64.StdEncoding.DecodeString(compact)
+	if err != nil {
+		return nil, WrapError(err, "encrypted key share must be hex, base64 or ASCII-armored")
+	}
+	return message, nil
+}
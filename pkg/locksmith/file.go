@@ -1,24 +1,353 @@
 package locksmith
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
+
+	"github.com/keybase/go-crypto/openpgp/armor"
+)
+
+// KeyFileFormat selects how WriteKeysToFile lays out the encrypted keys.
+type KeyFileFormat string
+
+const (
+	TextKeyFileFormat KeyFileFormat = "text"
+	// JSONKeyFileFormat writes a versioned KeyFile document for other tools to parse.
+	JSONKeyFileFormat KeyFileFormat = "json"
+	// ArmoredKeyFileFormat writes a directory with one ASCII-armored share per participant,
+	// so each file can be handed to its owner without revealing the other participants.
+	ArmoredKeyFileFormat KeyFileFormat = "armored"
+	// PaperKeyFileFormat adds a printable HTML page per participant to the armored format,
+	// with the share as QR codes that locksmith scan reads back.
+	PaperKeyFileFormat KeyFileFormat = "paper"
 )
 
-func WriteKeysToFile(vaultURL string, input WriteKeysToFileRequest) error {
-	output := fmt.Sprintf("VAULT URL: %s\n\n", vaultURL)
+// KeyFileVersion is incremented whenever a change to KeyFile would break existing parsers.
+const KeyFileVersion = 1
+
+func ParseKeyFileFormat(value string) (KeyFileFormat, error) {
+	switch KeyFileFormat(strings.ToLower(strings.TrimSpace(value))) {
+	case TextKeyFileFormat, "":
+		return TextKeyFileFormat, nil
+	case JSONKeyFileFormat:
+		return JSONKeyFileFormat, nil
+	case ArmoredKeyFileFormat:
+		return ArmoredKeyFileFormat, nil
+	case PaperKeyFileFormat:
+		return PaperKeyFileFormat, nil
+	}
+	return "", errors.New("invalid output format, must be one of text, json, armored or paper: " + value)
+}
+
+// KeyFile is the JSON key file document.
+type KeyFile struct {
+	Version         int                  `json:"version"`
+	VaultURL        string               `json:"vault_url"`
+	Namespace       string               `json:"namespace,omitempty"`
+	KeyType         KeyType              `json:"key_type"`
+	Nonce           string               `json:"nonce"`
+	CreatedAt       time.Time            `json:"created_at"`
+	SecretShares    int                  `json:"secret_shares"`
+	SecretThreshold int                  `json:"secret_threshold"`
+	Participants    []KeyFileParticipant `json:"participants"`
+}
+
+type KeyFileParticipant struct {
+	Name               string `json:"name"`
+	Identity           string `json:"identity,omitempty"`
+	Fingerprint        string `json:"fingerprint"`
+	EncryptedKey       string `json:"encrypted_key"`
+	EncryptedKeyBase64 string `json:"encrypted_key_base64"`
+}
+
+// ReadKeyFile reads a JSON key file written by WriteKeysToFile.
+func ReadKeyFile(path string) (KeyFile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return KeyFile{}, WrapError(err, "failed to read key file")
+	}
+	var keyFile KeyFile
+	err = json.Unmarshal(contents, &keyFile)
+	if err != nil {
+		return KeyFile{}, WrapError(err, "failed to parse key file")
+	}
+	if keyFile.Version < 1 || keyFile.Version > KeyFileVersion {
+		return KeyFile{}, fmt.Errorf("unsupported key file version %d, expected at most %d", keyFile.Version, KeyFileVersion)
+	}
+	return keyFile, nil
+}
+
+// WriteKeysToFile writes the encrypted keys to a new file and returns its name.
+// The armored and paper formats write a new directory instead, and return its name.
+func WriteKeysToFile(vaultURL string, input WriteKeysToFileRequest) (string, error) {
+	keyType := input.keyType()
+	if input.Format == ArmoredKeyFileFormat || input.Format == PaperKeyFileFormat {
+		return writeArmoredKeys(vaultURL, keyType, input)
+	}
+
+	var output []byte
+	extension := "txt"
+	switch input.Format {
+	case JSONKeyFileFormat:
+		var err error
+		output, err = EncodeKeyFile(vaultURL, input)
+		if err != nil {
+			return "", err
+		}
+		extension = "json"
+	default:
+		output = []byte(keysText(vaultURL, input))
+	}
+
+	fileName := keysBaseName(keyType) + "." + extension
+	if input.Directory != "" {
+		err := os.MkdirAll(input.Directory, 0700)
+		if err != nil {
+			return "", WrapError(err, "failed to create output directory")
+		}
+		fileName = filepath.Join(input.Directory, fileName)
+	}
+	err := writeFileExclusive(fileName, output)
+	if err != nil {
+		return "", WrapError(err, "failed to write to file")
+	}
+	fmt.Printf("✍️  New %s keys saved to: %s\n", keyType, fileName)
+	return fileName, nil
+}
+
+// EncodeKeyFile encodes the encrypted keys as a JSON key file document.
+func EncodeKeyFile(vaultURL string, input WriteKeysToFileRequest) ([]byte, error) {
+	// Identities such as "alice <alice@example.com>" stay readable without HTML escaping
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(newKeyFile(vaultURL, input.keyType(), input))
+	if err != nil {
+		return nil, WrapError(err, "failed to encode key file")
+	}
+	return buffer.Bytes(), nil
+}
+
+// keysBaseName names the output of a ceremony, e.g. "recovery-keys-2006-01-02-15-04-05".
+func keysBaseName(keyType KeyType) string {
+	return fmt.Sprintf("%s-keys-%s", keyType, time.Now().Format("2006-01-02-15-04-05"))
+}
+
+// PrintKeys prints the encrypted keys in the text format, as a fallback when they could not be saved.
+func PrintKeys(vaultURL string, input WriteKeysToFileRequest) {
+	fmt.Print(keysText(vaultURL, input))
+}
+
+// writeFileExclusive writes data readable only by the owner, through a synced temporary file
+// that is linked into place, so a crash never leaves a partial key file and an existing file
+// is never overwritten.
+func writeFileExclusive(fileName string, data []byte) error {
+	temp, err := writeTempFile(fileName, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	// Unlike a rename, a link fails if the file already exists
+	err = os.Link(temp, fileName)
+	if errors.Is(err, os.ErrExist) {
+		return errors.New("refusing to overwrite existing file: " + fileName)
+	}
+	if err != nil {
+		// Some filesystems do not support hard links
+		if _, statErr := os.Lstat(fileName); statErr == nil {
+			return errors.New("refusing to overwrite existing file: " + fileName)
+		}
+		err = os.Rename(temp, fileName)
+		if err != nil {
+			return err
+		}
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// writeFileAtomic replaces a file readable only by the owner, so a crash leaves either
+// the old or the new contents.
+func writeFileAtomic(fileName string, data []byte) error {
+	temp, err := writeTempFile(fileName, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	err = os.Rename(temp, fileName)
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// writeTempFile writes data to a synced temporary file, readable only by the owner,
+// next to fileName and returns its path.
+func writeTempFile(fileName string, data []byte) (string, error) {
+	temp, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	err = temp.Chmod(0600)
+	if err == nil {
+		_, err = temp.Write(data)
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	closeErr := temp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	return temp.Name(), nil
+}
+
+// syncDir makes a newly created directory entry durable.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+func keysText(vaultURL string, input WriteKeysToFileRequest) string {
+	output := fmt.Sprintf("VAULT URL: %s\n", vaultURL)
+	if input.KeyType != "" {
+		output += fmt.Sprintf("KEY TYPE: %s\n", input.KeyType)
+	}
+	if input.Namespace != "" {
+		output += fmt.Sprintf("VAULT NAMESPACE: %s\n", input.Namespace)
+	}
+	output += "\n"
 	for i, key := range input.Keys {
-		user := input.KeybaseUsers[i]
+		participant := input.Participants[i]
 		fingerprint := input.PGPFingerprints[i]
 		keyBase64 := input.KeysBase64[i]
-		output += fmt.Sprintf("KEYBASE USER: %s\nFINGERPRINT: %s\nENCRYPTED_KEY: %s\nENCRYPTED_KEY_BASE64: %s\n\n", user, fingerprint, key, keyBase64)
+		output += fmt.Sprintf("PARTICIPANT: %s\nFINGERPRINT: %s\nENCRYPTED_KEY: %s\nENCRYPTED_KEY_BASE64: %s\n\n", participant, fingerprint, key, keyBase64)
 	}
-	fileName := fmt.Sprintf("recovery-keys-%s.txt", time.Now().Format("2006-01-02-15-04-05"))
-	err := ioutil.WriteFile(fileName, []byte(output), 0644)
+	return output
+}
+
+func newKeyFile(vaultURL string, keyType KeyType, input WriteKeysToFileRequest) KeyFile {
+	keyFile := KeyFile{
+		Version:         KeyFileVersion,
+		VaultURL:        vaultURL,
+		Namespace:       input.Namespace,
+		KeyType:         keyType,
+		Nonce:           input.Nonce,
+		CreatedAt:       time.Now().UTC(),
+		SecretShares:    input.SecretShares,
+		SecretThreshold: input.SecretThreshold,
+	}
+	for i, key := range input.Keys {
+		participant := KeyFileParticipant{
+			Name:               input.Participants[i],
+			Fingerprint:        input.PGPFingerprints[i],
+			EncryptedKey:       key,
+			EncryptedKeyBase64: input.KeysBase64[i],
+		}
+		if i < len(input.Identities) {
+			participant.Identity = input.Identities[i]
+		}
+		keyFile.Participants = append(keyFile.Participants, participant)
+	}
+	return keyFile
+}
+
+func writeArmoredKeys(vaultURL string, keyType KeyType, input WriteKeysToFileRequest) (string, error) {
+	if input.Directory != "" {
+		err := os.MkdirAll(input.Directory, 0700)
+		if err != nil {
+			return "", WrapError(err, "failed to create output directory")
+		}
+	}
+	dirName := filepath.Join(input.Directory, keysBaseName(keyType))
+	err := os.Mkdir(dirName, 0700)
+	if err != nil {
+		return "", WrapError(err, "failed to create output directory")
+	}
+
+	fmt.Printf("✍️  New %s keys saved to: %s\n", keyType, dirName)
+	for i, keyBase64 := range input.KeysBase64 {
+		message, err := armorShare(keyType, keyBase64)
+		if err != nil {
+			return "", WrapError(err, "failed to armor key share for "+input.Participants[i])
+		}
+		fileName := filepath.Join(dirName, shareFileName(input.Participants[i], input.PGPFingerprints[i]))
+		err = writeFileExclusive(fileName, message)
+		if err != nil {
+			return "", WrapError(err, "failed to write to file")
+		}
+		fmt.Printf("   %s: %s\n", input.Participants[i], filepath.Base(fileName))
+
+		if input.Format == PaperKeyFileFormat {
+			page, err := renderPaperShare(vaultURL, keyType, input, i, message)
+			if err != nil {
+				return "", WrapError(err, "failed to render printable share for "+input.Participants[i])
+			}
+			pageName := strings.TrimSuffix(fileName, ".asc") + ".html"
+			err = writeFileExclusive(pageName, page)
+			if err != nil {
+				return "", WrapError(err, "failed to write to file")
+			}
+			fmt.Printf("   %s: %s\n", input.Participants[i], filepath.Base(pageName))
+		}
+	}
+	return dirName, nil
+}
+
+func armorShare(keyType KeyType, keyBase64 string) ([]byte, error) {
+	message, err := base64.StdEncoding.DecodeString(keyBase64)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	writer, err := armor.Encode(&buffer, "PGP MESSAGE", map[string]string{
+		"Comment": fmt.Sprintf("Vault %s key share, decrypt with gpg --decrypt", keyType),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = writer.Write(message)
 	if err != nil {
-		return WrapError(err, "failed to write to file")
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes(), nil
+}
+
+// shareFileName names a participant's share file after them and their key, e.g. "alice-93407dde0bd0b189.asc".
+func shareFileName(participant string, fingerprint string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._@-", r) {
+			return r
+		}
+		return '_'
+	}, participant)
+	fingerprint = normalizeFingerprint(fingerprint)
+	if len(fingerprint) > 16 {
+		fingerprint = fingerprint[len(fingerprint)-16:]
 	}
-	fmt.Printf("✍️  New recovery keys saved to: %s\n", fileName)
-	return nil
+	return name + "-" + fingerprint + ".asc"
 }
@@ -0,0 +1,214 @@
+package locksmith
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/keybase/go-crypto/openpgp"
+	"github.com/keybase/go-crypto/openpgp/packet"
+)
+
+const (
+	minRSABits    = 2048
+	expiryWarning = 30 * 24 * time.Hour
+)
+
+// KeyCheck is the result of validating a participant's public key before it is sent to Vault.
+type KeyCheck struct {
+	Participant Participant
+	Fingerprint string
+	Identity    string
+	Algorithm   string
+	Warnings    []string
+	Errors      []string
+}
+
+func (k KeyCheck) OK() bool {
+	return len(k.Errors) == 0
+}
+
+// CheckPublicKeys validates the public keys returned by FetchPublicKeys.
+// Keys shared by several participants are rejected, since one person would hold several shares.
+func CheckPublicKeys(participants []Participant, keys []string) []KeyCheck {
+	now := time.Now()
+	seen := map[string]string{}
+
+	var checks []KeyCheck
+	for i, participant := range participants {
+		var key string
+		if i < len(keys) {
+			key = keys[i]
+		}
+		check := checkPublicKey(key, now)
+		check.Participant = participant
+		if check.Fingerprint != "" {
+			if other, ok := seen[check.Fingerprint]; ok {
+				check.Errors = append(check.Errors, "same key as participant "+other)
+			}
+			seen[check.Fingerprint] = participant.Name
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// KeyCheckErrors returns an error describing every failed check, or nil.
+func KeyCheckErrors(checks []KeyCheck) error {
+	var message string
+	for _, check := range checks {
+		for _, err := range check.Errors {
+			if message != "" {
+				message += ", "
+			}
+			message += check.Participant.Name + ": " + err
+		}
+	}
+	if message == "" {
+		return nil
+	}
+	return fmt.Errorf("invalid participant keys: %s", message)
+}
+
+func checkPublicKey(key string, now time.Time) KeyCheck {
+	var check KeyCheck
+	if key == "" {
+		check.Errors = append(check.Errors, "no public key found")
+		return check
+	}
+	entity, err := readEntity(key)
+	if err != nil {
+		check.Errors = append(check.Errors, "failed to parse public key: "+err.Error())
+		return check
+	}
+
+	check.Fingerprint = Fingerprint(entity)
+	check.Algorithm = describeAlgorithm(entity.PrimaryKey)
+	identity := primaryIdentity(entity)
+	if identity != nil {
+		check.Identity = identity.Name
+	}
+
+	// Primary key state
+	if len(entity.Revocations) > 0 {
+		check.Errors = append(check.Errors, "key is revoked")
+	}
+	if len(entity.UnverifiedRevocations) > 0 {
+		check.Warnings = append(check.Warnings, "key carries a revocation from a designated revoker")
+	}
+	if identity == nil || identity.SelfSignature == nil {
+		check.Errors = append(check.Errors, "key has no self-signed identity")
+		return check
+	}
+	if identity.SelfSignature.KeyExpired(now) {
+		check.Errors = append(check.Errors, "key expired on "+keyExpiry(entity.PrimaryKey, identity.SelfSignature).Format("2006-01-02"))
+	}
+	check.Warnings = append(check.Warnings, algorithmWarnings(entity.PrimaryKey)...)
+
+	// Vault encrypts each share to the key's encryption subkey
+	encryptionKey, signature, ok := encryptionKey(entity, identity, now)
+	if !ok {
+		check.Errors = append(check.Errors, "key has no valid encryption key, it may be signing-only, expired or revoked")
+		return check
+	}
+	if encryptionKey != entity.PrimaryKey {
+		for _, warning := range algorithmWarnings(encryptionKey) {
+			check.Warnings = append(check.Warnings, "encryption subkey: "+warning)
+		}
+	}
+	if signature.KeyLifetimeSecs != nil && *signature.KeyLifetimeSecs != 0 {
+		expiry := keyExpiry(encryptionKey, signature)
+		if expiry.Sub(now) < expiryWarning {
+			check.Warnings = append(check.Warnings, "encryption key expires on "+expiry.Format("2006-01-02"))
+		}
+	}
+	return check
+}
+
+// encryptionKey mirrors the subkey selection openpgp uses when Vault encrypts a share.
+func encryptionKey(entity *openpgp.Entity, identity *openpgp.Identity, now time.Time) (*packet.PublicKey, *packet.Signature, bool) {
+	var best *openpgp.Subkey
+	for i, subkey := range entity.Subkeys {
+		if subkey.Sig == nil || subkey.Revocation != nil || subkey.Sig.KeyExpired(now) {
+			continue
+		}
+		if !subkey.PublicKey.PubKeyAlgo.CanEncrypt() {
+			continue
+		}
+		flagged := subkey.Sig.FlagsValid && subkey.Sig.FlagEncryptCommunications
+		implicit := !subkey.Sig.FlagsValid && subkey.PublicKey.PubKeyAlgo == packet.PubKeyAlgoElGamal
+		if !flagged && !implicit {
+			continue
+		}
+		if best == nil || subkey.Sig.CreationTime.After(best.Sig.CreationTime) {
+			best = &entity.Subkeys[i]
+		}
+	}
+	if best != nil {
+		return best.PublicKey, best.Sig, true
+	}
+
+	signature := identity.SelfSignature
+	if (!signature.FlagsValid || signature.FlagEncryptCommunications) &&
+		entity.PrimaryKey.PubKeyAlgo.CanEncrypt() &&
+		!signature.KeyExpired(now) {
+		return entity.PrimaryKey, signature, true
+	}
+	return nil, nil, false
+}
+
+func primaryIdentity(entity *openpgp.Entity) *openpgp.Identity {
+	var first *openpgp.Identity
+	for _, identity := range entity.Identities {
+		if identity.Revocation != nil {
+			continue
+		}
+		if first == nil {
+			first = identity
+		}
+		if identity.SelfSignature != nil && identity.SelfSignature.IsPrimaryId != nil && *identity.SelfSignature.IsPrimaryId {
+			return identity
+		}
+	}
+	return first
+}
+
+func keyExpiry(key *packet.PublicKey, signature *packet.Signature) time.Time {
+	return key.CreationTime.Add(time.Duration(*signature.KeyLifetimeSecs) * time.Second)
+}
+
+func describeAlgorithm(key *packet.PublicKey) string {
+	name := "unknown"
+	switch key.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSAEncryptOnly, packet.PubKeyAlgoRSASignOnly:
+		name = "RSA"
+	case packet.PubKeyAlgoDSA:
+		name = "DSA"
+	case packet.PubKeyAlgoElGamal, packet.PubKeyAlgoBadElGamal:
+		name = "ElGamal"
+	case packet.PubKeyAlgoECDH:
+		name = "ECDH"
+	case packet.PubKeyAlgoECDSA:
+		name = "ECDSA"
+	case packet.PubKeyAlgoEdDSA:
+		name = "EdDSA"
+	}
+	bits, err := key.BitLength()
+	if err != nil {
+		return name
+	}
+	return fmt.Sprintf("%s %d", name, bits)
+}
+
+func algorithmWarnings(key *packet.PublicKey) []string {
+	var warnings []string
+	bits, _ := key.BitLength()
+	switch key.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSAEncryptOnly, packet.PubKeyAlgoRSASignOnly:
+		if bits < minRSABits {
+			warnings = append(warnings, fmt.Sprintf("weak RSA key size of %d bits, at least %d is recommended", bits, minRSABits))
+		}
+	case packet.PubKeyAlgoDSA, packet.PubKeyAlgoElGamal, packet.PubKeyAlgoBadElGamal:
+		warnings = append(warnings, "legacy "+describeAlgorithm(key)+" key, consider a modern RSA or Curve25519 key")
+	}
+	return warnings
+}
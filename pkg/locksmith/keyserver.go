@@ -0,0 +1,164 @@
+package locksmith
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/keybase/go-crypto/openpgp"
+)
+
+const maxKeyResponseSize = 1 << 20
+
+// zbase32 is the encoding used for WKD local part hashes (RFC 6189).
+var zbase32 = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").WithPadding(base32.NoPadding)
+
+func (f KeyFetcher) httpClient() *http.Client {
+	if f.HTTPClient != nil {
+		return f.HTTPClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// fetchWKD looks up an email address using the advanced WKD method,
+// falling back to the direct method.
+func (f KeyFetcher) fetchWKD(email string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", errors.New("invalid WKD email address: " + email)
+	}
+	local := email[:at]
+	domain := strings.ToLower(email[at+1:])
+	hash := sha1.Sum([]byte(strings.ToLower(local)))
+	hu := zbase32.EncodeToString(hash[:])
+	query := "?l=" + url.QueryEscape(local)
+
+	urls := []string{
+		fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s%s", domain, domain, hu, query),
+		fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s%s", domain, hu, query),
+	}
+	var lastErr error
+	for _, u := range urls {
+		data, err := f.get(u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		entities, err := parsePublicKeys(data)
+		if err != nil {
+			return "", err
+		}
+		entity, err := entityForEmail(entities, email)
+		if err != nil {
+			return "", err
+		}
+		return serializePublicKey(entity)
+	}
+	return "", WrapError(lastErr, "WKD lookup failed for "+email)
+}
+
+// fetchHKP retrieves a key by fingerprint from the configured keyserver,
+// rejecting any key whose fingerprint does not match the one requested.
+func (f KeyFetcher) fetchHKP(fingerprint string) (string, error) {
+	if f.KeyserverURL == "" {
+		return "", errors.New("no HKP keyserver configured")
+	}
+	if len(fingerprint) != 40 {
+		return "", errors.New("HKP lookups require a full 40 character fingerprint: " + fingerprint)
+	}
+	if _, err := hex.DecodeString(fingerprint); err != nil {
+		return "", errors.New("invalid fingerprint: " + fingerprint)
+	}
+	base, err := keyserverBaseURL(f.KeyserverURL)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := f.get(base + "/pks/lookup?op=get&options=mr&search=0x" + fingerprint)
+	if err != nil {
+		return "", WrapError(err, "HKP lookup failed for "+fingerprint)
+	}
+	entities, err := parsePublicKeys(data)
+	if err != nil {
+		return "", err
+	}
+	for _, entity := range entities {
+		if Fingerprint(entity) == fingerprint {
+			return serializePublicKey(entity)
+		}
+	}
+	return "", errors.New("keyserver did not return a key matching fingerprint " + fingerprint)
+}
+
+func (f KeyFetcher) get(u string) ([]byte, error) {
+	resp, err := f.httpClient().Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status code: " + resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxKeyResponseSize))
+}
+
+// keyserverBaseURL maps hkp:// and hkps:// keyserver URLs to their HTTP equivalents.
+func keyserverBaseURL(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", WrapError(err, "invalid keyserver url")
+	}
+	switch parsed.Scheme {
+	case "hkp":
+		parsed.Scheme = "http"
+		if parsed.Port() == "" {
+			parsed.Host += ":11371"
+		}
+	case "hkps":
+		parsed.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", errors.New("invalid keyserver url, scheme must be hkp, hkps, http or https: " + raw)
+	}
+	if parsed.Host == "" {
+		return "", errors.New("invalid keyserver url, missing host: " + raw)
+	}
+	return strings.TrimRight(parsed.String(), "/"), nil
+}
+
+// entityForEmail picks the key with a user ID for the email, even when WKD returns a single key,
+// since a key served for the address is not necessarily one its owner holds.
+func entityForEmail(entities openpgp.EntityList, email string) (*openpgp.Entity, error) {
+	var matches []*openpgp.Entity
+	for _, entity := range entities {
+		for _, identity := range entity.Identities {
+			if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, email) {
+				matches = append(matches, entity)
+				break
+			}
+		}
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("expected exactly one key for %s, found %d", email, len(matches))
+	}
+	return matches[0], nil
+}
+
+// Fingerprint returns the lowercase hex fingerprint of an entity's primary key,
+// matching the format of RekeyStatus.PGPFingerprints.
+func Fingerprint(entity *openpgp.Entity) string {
+	return hex.EncodeToString(entity.PrimaryKey.Fingerprint[:])
+}
+
+// normalizeFingerprint lowercases a fingerprint and strips spaces and any 0x prefix.
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.ToLower(strings.Join(strings.Fields(fingerprint), ""))
+	return strings.TrimPrefix(fingerprint, "0x")
+}
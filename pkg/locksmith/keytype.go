@@ -0,0 +1,76 @@
+package locksmith
+
+import (
+	"errors"
+	"strings"
+)
+
+// KeyType selects which set of keys a ceremony rekeys.
+type KeyType string
+
+const (
+	// RecoveryKeyType rekeys the recovery keys of an auto-unseal cluster.
+	RecoveryKeyType KeyType = "recovery"
+	// UnsealKeyType rekeys the unseal keys of a Shamir-sealed cluster.
+	UnsealKeyType KeyType = "unseal"
+	// AutoKeyType detects the key type from the cluster's seal status.
+	AutoKeyType KeyType = "auto"
+)
+
+func ParseKeyType(value string) (KeyType, error) {
+	switch KeyType(strings.ToLower(strings.TrimSpace(value))) {
+	case RecoveryKeyType:
+		return RecoveryKeyType, nil
+	case UnsealKeyType:
+		return UnsealKeyType, nil
+	case AutoKeyType, "":
+		return AutoKeyType, nil
+	}
+	return "", errors.New("invalid key type, must be one of recovery, unseal or auto: " + value)
+}
+
+func (k KeyType) String() string {
+	return string(k)
+}
+
+// rekeyPath returns the API path prefix of the rekey endpoints for the key type.
+// An undetected AutoKeyType uses the recovery key endpoints.
+func (k KeyType) rekeyPath() string {
+	if k == UnsealKeyType {
+		return "/v1/sys/rekey"
+	}
+	return "/v1/sys/rekey-recovery-key"
+}
+
+func WithKeyType(keyType KeyType) ClientOption {
+	return func(c *Client) error {
+		if _, err := ParseKeyType(string(keyType)); err != nil {
+			return err
+		}
+		c.keyType = keyType
+		return nil
+	}
+}
+
+func (c *Client) KeyType() KeyType {
+	return c.keyType
+}
+
+// DetectKeyType resolves AutoKeyType by querying sys/seal-status. Clusters
+// with a recovery seal use recovery keys, all others use unseal keys.
+// An explicitly configured key type is returned unchanged.
+func (c *Client) DetectKeyType() (KeyType, error) {
+	if c.keyType != AutoKeyType {
+		return c.keyType, nil
+	}
+	status, err := c.GetSealStatus()
+	if err != nil {
+		return "", WrapError(err, "failed to detect key type")
+	}
+	if status.RecoverySeal {
+		c.keyType = RecoveryKeyType
+	} else {
+		c.keyType = UnsealKeyType
+	}
+	return c.keyType, nil
+}
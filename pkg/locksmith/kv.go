@@ -0,0 +1,95 @@
+package locksmith
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// kvTokenEnv holds the token of the cluster a VaultKVSink writes to, which is not the cluster being rekeyed.
+const kvTokenEnv = "LOCKSMITH_KV_TOKEN"
+
+// VaultKVSink writes the JSON key file to a KV version 2 secrets engine, usually on a
+// separate cluster, so that the keys are not stored behind the keys they protect.
+type VaultKVSink struct {
+	Client *Client
+	Mount  string
+	Path   string
+}
+
+// parseVaultKVSink parses "vault-kv://mount/path?addr=...&namespace=...&token_file=...&ca_cert=...".
+// The token defaults to LOCKSMITH_KV_TOKEN.
+func parseVaultKVSink(spec string) (KeySink, error) {
+	u, query, err := parseVaultKVSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	token := strings.TrimSpace(os.Getenv(kvTokenEnv))
+	if query.Get("token_file") != "" {
+		token, err = readTokenFile(query.Get("token_file"))
+		if err != nil {
+			return nil, WrapError(err, "failed to read vault-kv token file")
+		}
+	}
+	if token == "" {
+		return nil, errors.New("vault-kv sink requires a token in " + kvTokenEnv + " or the token_file option")
+	}
+
+	client, err := NewClient(
+		query.Get("addr"),
+		WithToken(token),
+		WithNamespace(query.Get("namespace")),
+		WithTLSConfig(TLSConfig{CACert: query.Get("ca_cert")}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return VaultKVSink{Client: client, Mount: u.Host, Path: strings.Trim(u.Path, "/")}, nil
+}
+
+func parseVaultKVSpec(spec string) (*url.URL, url.Values, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, nil, WrapError(err, "invalid vault-kv sink")
+	}
+	if u.Host == "" {
+		return nil, nil, errors.New("vault-kv sink requires a mount: " + spec)
+	}
+	query, err := sinkOptions(u, "addr", "namespace", "token_file", "ca_cert")
+	if err != nil {
+		return nil, nil, err
+	}
+	if query.Get("addr") == "" {
+		return nil, nil, errors.New("vault-kv sink requires the addr option: " + spec)
+	}
+	return u, query, nil
+}
+
+func (s VaultKVSink) Store(vaultURL string, keys WriteKeysToFileRequest) (string, error) {
+	document, err := EncodeKeyFile(vaultURL, keys)
+	if err != nil {
+		return "", err
+	}
+	var data map[string]interface{}
+	err = json.Unmarshal(document, &data)
+	if err != nil {
+		return "", WrapError(err, "failed to encode key file")
+	}
+
+	path := keysBaseName(keys.keyType())
+	if s.Path != "" {
+		path = s.Path + "/" + path
+	}
+	err = s.Client.WriteKVSecret(s.Mount, path, data)
+	if err != nil {
+		return "", err
+	}
+	return s.Client.URL() + "/v1/" + s.Mount + "/data/" + path, nil
+}
+
+func (s VaultKVSink) String() string {
+	return "vault-kv://" + s.Mount + "/" + s.Path
+}
@@ -0,0 +1,230 @@
+package locksmith
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+)
+
+const (
+	// qrChunkPrefix starts every QR code payload, so scan can tell share codes from other codes
+	qrChunkPrefix = "LOCKSMITH1"
+	// qrChunkSize is the number of base64 characters per QR code, small enough to scan from paper
+	qrChunkSize = 600
+	// qrMaxParts bounds the parts read from a QR code. A share encrypted to an RSA 4096 key
+	// needs two codes, so anything near this is damaged or not a share.
+	qrMaxParts = 16
+	// qrModulePixels is the size of a QR code module in the rendered PNG
+	qrModulePixels = 8
+)
+
+// qrChunks splits an encrypted share into the payloads of its QR codes:
+//
+//	LOCKSMITH1:<key type>:<part>/<parts>:<sha256>:<base64>
+//
+// The SHA-256 is of the whole binary PGP message, as in the manifest.
+func qrChunks(keyType KeyType, message []byte) ([]string, error) {
+	encoded := base64.StdEncoding.EncodeToString(message)
+	hash := sha256Hex(message)
+	parts := (len(encoded) + qrChunkSize - 1) / qrChunkSize
+	if parts > qrMaxParts {
+		return nil, fmt.Errorf("key share needs %d QR codes, at most %d are supported", parts, qrMaxParts)
+	}
+	var chunks []string
+	for part := 1; len(encoded) > 0; part++ {
+		size := qrChunkSize
+		if len(encoded) < size {
+			size = len(encoded)
+		}
+		chunks = append(chunks, fmt.Sprintf("%s:%s:%d/%d:%s:%s", qrChunkPrefix, keyType, part, parts, hash, encoded[:size]))
+		encoded = encoded[size:]
+	}
+	return chunks, nil
+}
+
+// qrChunk is a parsed QR code payload.
+type qrChunk struct {
+	KeyType KeyType
+	Part    int
+	Parts   int
+	SHA256  string
+	Data    string
+}
+
+func parseQRChunk(payload string) (qrChunk, error) {
+	fields := strings.Split(strings.TrimSpace(payload), ":")
+	if len(fields) != 5 || fields[0] != qrChunkPrefix {
+		return qrChunk{}, errors.New("not a locksmith key share QR code")
+	}
+	part, parts, found := strings.Cut(fields[2], "/")
+	if !found {
+		return qrChunk{}, errors.New("invalid part number in QR code: " + fields[2])
+	}
+	keyType, err := ParseKeyType(fields[1])
+	if err != nil || keyType == AutoKeyType {
+		return qrChunk{}, errors.New("invalid key type in QR code, must be recovery or unseal")
+	}
+	chunk := qrChunk{KeyType: keyType, SHA256: fields[3], Data: fields[4]}
+	chunk.Part, err = strconv.Atoi(part)
+	if err == nil {
+		chunk.Parts, err = strconv.Atoi(parts)
+	}
+	if err != nil || chunk.Part < 1 || chunk.Part > chunk.Parts || chunk.Parts > qrMaxParts {
+		return qrChunk{}, errors.New("invalid part number in QR code: " + fields[2])
+	}
+	if len(chunk.Data) > qrChunkSize {
+		return qrChunk{}, fmt.Errorf("QR code holds %d characters of key share, at most %d are expected", len(chunk.Data), qrChunkSize)
+	}
+	return chunk, nil
+}
+
+// renderQRCode renders a QR code as a PNG data URI.
+func renderQRCode(payload string) (template.URL, error) {
+	hints := map[gozxing.EncodeHintType]interface{}{
+		gozxing.EncodeHintType_ERROR_CORRECTION: decoder.ErrorCorrectionLevel_M,
+	}
+	matrix, err := qrcode.NewQRCodeWriter().Encode(payload, gozxing.BarcodeFormat_QR_CODE, 0, 0, hints)
+	if err != nil {
+		return "", WrapError(err, "failed to encode QR code")
+	}
+
+	width, height := matrix.GetWidth(), matrix.GetHeight()
+	img := image.NewGray(image.Rect(0, 0, width*qrModulePixels, height*qrModulePixels))
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			value := color.Gray{Y: 255}
+			if matrix.Get(x/qrModulePixels, y/qrModulePixels) {
+				value = color.Gray{Y: 0}
+			}
+			img.SetGray(x, y, value)
+		}
+	}
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, img)
+	if err != nil {
+		return "", WrapError(err, "failed to encode QR code")
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes())), nil
+}
+
+type paperQRCode struct {
+	Image template.URL
+	Part  int
+	Parts int
+}
+
+type paperPage struct {
+	VaultURL    string
+	Namespace   string
+	KeyType     KeyType
+	Nonce       string
+	CreatedAt   string
+	Participant string
+	Fingerprint string
+	SHA256      string
+	QRCodes     []paperQRCode
+	Armored     string
+}
+
+// renderPaperShare renders a printable HTML page for one participant's encrypted share.
+// Browsers can print it, or save it as a PDF.
+func renderPaperShare(vaultURL string, keyType KeyType, input WriteKeysToFileRequest, i int, armored []byte) ([]byte, error) {
+	message, err := base64.StdEncoding.DecodeString(input.KeysBase64[i])
+	if err != nil {
+		return nil, err
+	}
+	page := paperPage{
+		VaultURL:    vaultURL,
+		Namespace:   input.Namespace,
+		KeyType:     keyType,
+		Nonce:       input.Nonce,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Participant: input.Participants[i],
+		Fingerprint: formatPaperFingerprint(input.PGPFingerprints[i]),
+		SHA256:      sha256Hex(message),
+		Armored:     string(armored),
+	}
+	chunks, err := qrChunks(keyType, message)
+	if err != nil {
+		return nil, err
+	}
+	for part, chunk := range chunks {
+		qr, err := renderQRCode(chunk)
+		if err != nil {
+			return nil, err
+		}
+		page.QRCodes = append(page.QRCodes, paperQRCode{Image: qr, Part: part + 1, Parts: len(chunks)})
+	}
+
+	var buffer bytes.Buffer
+	err = paperTemplate.Execute(&buffer, page)
+	if err != nil {
+		return nil, WrapError(err, "failed to render printable share")
+	}
+	return buffer.Bytes(), nil
+}
+
+func formatPaperFingerprint(fingerprint string) string {
+	fingerprint = strings.ToUpper(normalizeFingerprint(fingerprint))
+	var blocks []string
+	for len(fingerprint) > 4 {
+		blocks = append(blocks, fingerprint[:4])
+		fingerprint = fingerprint[4:]
+	}
+	return strings.Join(append(blocks, fingerprint), " ")
+}
+
+var paperTemplate = template.Must(template.New("paper").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Vault {{.KeyType}} key share for {{.Participant}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+th { text-align: left; padding-right: 1em; vertical-align: top; }
+code, pre { font-family: monospace; word-break: break-all; }
+pre { font-size: 9pt; white-space: pre-wrap; }
+.qr { display: inline-block; margin: 0 1em 1em 0; text-align: center; page-break-inside: avoid; }
+.qr img { width: 7cm; image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<h1>Vault {{.KeyType}} key share</h1>
+<table>
+<tr><th>Participant</th><td>{{.Participant}}</td></tr>
+<tr><th>Vault</th><td>{{.VaultURL}}</td></tr>
+{{- if .Namespace}}
+<tr><th>Namespace</th><td>{{.Namespace}}</td></tr>
+{{- end}}
+<tr><th>Nonce</th><td><code>{{.Nonce}}</code></td></tr>
+<tr><th>Created</th><td>{{.CreatedAt}}</td></tr>
+<tr><th>PGP key</th><td><code>{{.Fingerprint}}</code></td></tr>
+<tr><th>SHA-256</th><td><code>{{.SHA256}}</code></td></tr>
+</table>
+<p>
+This share is encrypted to the PGP key above. To restore it, scan every QR code with
+<code>locksmith scan</code>, or type in the message below and check that
+<code>gpg --dearmor &lt; share.asc | sha256sum</code> matches the SHA-256 above.
+Then decrypt it with <code>gpg --decrypt share.asc</code>.
+</p>
+<h2>QR codes</h2>
+{{range .QRCodes -}}
+<div class="qr"><img src="{{.Image}}" alt="Part {{.Part}} of {{.Parts}}"><br>Part {{.Part}} of {{.Parts}}</div>
+{{end -}}
+<h2>Encrypted share</h2>
+<pre>{{.Armored}}</pre>
+</body>
+</html>
+`))
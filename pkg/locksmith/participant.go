@@ -0,0 +1,141 @@
+package locksmith
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// KeySource identifies where a participant's PGP public key is fetched from.
+type KeySource string
+
+const (
+	KeybaseSource KeySource = "keybase"
+	FileSource    KeySource = "file"
+	Base64Source  KeySource = "base64"
+	// WKDSource looks up an email address through Web Key Directory.
+	WKDSource KeySource = "wkd"
+	// HKPSource looks up a fingerprint on the configured HKP keyserver.
+	HKPSource KeySource = "hkp"
+)
+
+var keySources = []KeySource{KeybaseSource, FileSource, Base64Source, WKDSource, HKPSource}
+
+// Participant is a member of a rekey ceremony who receives an encrypted key share.
+type Participant struct {
+	Name   string
+	Source KeySource
+	Value  string
+}
+
+// ParseParticipant parses a participant spec of the form "[name=]source:value",
+// such as "keybase:alice", "bob=file:keys/bob.asc", "base64:mQENBF...",
+// "wkd:carol@example.com" or "hkp:0x<fingerprint>".
+// Specs without a source are treated as Keybase users, unless they look like a key file path.
+func ParseParticipant(spec string) (Participant, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Participant{}, errors.New("participant must not be empty")
+	}
+
+	// An explicit name comes before the source, base64 padding comes after it
+	var name string
+	if eq := strings.Index(spec, "="); eq > 0 {
+		colon := strings.Index(spec, ":")
+		if colon == -1 || eq < colon {
+			name = strings.TrimSpace(spec[:eq])
+			spec = strings.TrimSpace(spec[eq+1:])
+		}
+	}
+
+	participant := Participant{Name: name}
+	for _, source := range keySources {
+		prefix := string(source) + ":"
+		if strings.HasPrefix(spec, prefix) {
+			participant.Source = source
+			participant.Value = strings.TrimSpace(strings.TrimPrefix(spec, prefix))
+			break
+		}
+	}
+	if participant.Source == "" {
+		participant.Source = KeybaseSource
+		participant.Value = spec
+		if looksLikeKeyFile(spec) {
+			participant.Source = FileSource
+			participant.Value = strings.TrimPrefix(spec, "@")
+		}
+	}
+	if participant.Value == "" {
+		return Participant{}, errors.New("participant is missing a value: " + spec)
+	}
+	if participant.Source == HKPSource {
+		participant.Value = normalizeFingerprint(participant.Value)
+	}
+	if participant.Name == "" {
+		participant.Name = participant.defaultName()
+	}
+	return participant, nil
+}
+
+// ParseParticipants parses a list of participant specs, rejecting empty and duplicate entries.
+func ParseParticipants(specs []string) ([]Participant, error) {
+	var participants []Participant
+	for i, spec := range specs {
+		if strings.TrimSpace(spec) == "" {
+			return nil, fmt.Errorf("participant %d is empty", i+1)
+		}
+		participant, err := ParseParticipant(spec)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	err := validateUniqueParticipants(participants)
+	if err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
+
+// String returns the spec the participant was parsed from.
+func (p Participant) String() string {
+	spec := string(p.Source) + ":" + p.Value
+	if p.Name != p.defaultName() {
+		spec = p.Name + "=" + spec
+	}
+	return spec
+}
+
+func (p Participant) defaultName() string {
+	switch p.Source {
+	case FileSource:
+		base := filepath.Base(p.Value)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	case Base64Source:
+		if len(p.Value) > 12 {
+			return "base64:" + p.Value[:12]
+		}
+		return "base64:" + p.Value
+	}
+	return p.Value
+}
+
+func looksLikeKeyFile(spec string) bool {
+	if strings.HasPrefix(spec, "@") || strings.ContainsRune(spec, filepath.Separator) {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(spec)) {
+	case ".asc", ".gpg", ".pgp", ".pub", ".key":
+		return true
+	}
+	return false
+}
+
+func ParticipantNames(participants []Participant) []string {
+	var names []string
+	for _, participant := range participants {
+		names = append(names, participant.Name)
+	}
+	return names
+}
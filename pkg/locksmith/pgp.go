@@ -0,0 +1,179 @@
+package locksmith
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/pgpkeys"
+	"github.com/keybase/go-crypto/openpgp"
+	"github.com/keybase/go-crypto/openpgp/packet"
+)
+
+// KeyFetcher resolves participant public keys from their key sources.
+type KeyFetcher struct {
+	// HTTPClient is used for WKD and HKP lookups. Defaults to a client with a 30 second timeout.
+	HTTPClient *http.Client
+	// KeyserverURL is the HKP keyserver used for "hkp:" participants, e.g. "hkps://keys.example.com".
+	KeyserverURL string
+}
+
+// FetchPublicKeys resolves the public key of each participant, in order, as
+// the base64-encoded binary keys expected by Vault.
+func FetchPublicKeys(participants []Participant) ([]string, error) {
+	return KeyFetcher{}.FetchPublicKeys(participants)
+}
+
+func (f KeyFetcher) FetchPublicKeys(participants []Participant) ([]string, error) {
+	keys := make([]string, len(participants))
+
+	// Keybase lookups are batched into a single request
+	var keybaseUsers []string
+	for _, participant := range participants {
+		if participant.Source == KeybaseSource {
+			keybaseUsers = append(keybaseUsers, "keybase:"+participant.Value)
+		}
+	}
+	keybaseKeys := map[string]string{}
+	if len(keybaseUsers) > 0 {
+		var err error
+		keybaseKeys, err = pgpkeys.FetchKeybasePubkeys(keybaseUsers)
+		if err != nil {
+			return nil, WrapError(err, "failed to fetch public keys from Keybase")
+		}
+	}
+
+	for i, participant := range participants {
+		var key string
+		var err error
+		switch participant.Source {
+		case KeybaseSource:
+			key = keybaseKeys["keybase:"+participant.Value]
+			if key == "" {
+				err = errors.New("keybase user not found")
+			}
+		case FileSource:
+			key, err = readPublicKeyFile(participant.Value)
+		case Base64Source:
+			key, err = decodeBase64PublicKey(participant.Value)
+		case WKDSource:
+			key, err = f.fetchWKD(participant.Value)
+		case HKPSource:
+			key, err = f.fetchHKP(participant.Value)
+		default:
+			err = errors.New("unsupported key source: " + string(participant.Source))
+		}
+		if err != nil {
+			return nil, WrapError(err, "failed to fetch public key for "+participant.String())
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+func readPublicKeyFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return normalizePublicKey(contents)
+}
+
+func decodeBase64PublicKey(value string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return "", WrapError(err, "invalid base64 public key")
+	}
+	return normalizePublicKey(decoded)
+}
+
+// normalizePublicKey accepts an armored, binary or base64-encoded public key and
+// returns it as a base64-encoded binary key containing exactly one entity.
+func normalizePublicKey(data []byte) (string, error) {
+	entities, err := parsePublicKeys(data)
+	if err != nil {
+		return "", err
+	}
+	if len(entities) != 1 {
+		return "", errors.New("expected exactly one public key, found " + strconv.Itoa(len(entities)))
+	}
+	return serializePublicKey(entities[0])
+}
+
+func parsePublicKeys(data []byte) (openpgp.EntityList, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	var entities openpgp.EntityList
+	var err error
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN")):
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(trimmed))
+	case isBase64(trimmed):
+		decoded, _ := base64.StdEncoding.DecodeString(string(trimmed))
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(decoded))
+	default:
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, WrapError(err, "failed to parse public key")
+	}
+	return entities, nil
+}
+
+// serializePublicKey serializes the public part of an entity. Unlike
+// Entity.Serialize it keeps key revocations, so that a revoked key is still
+// recognized as revoked after normalization.
+func serializePublicKey(entity *openpgp.Entity) (string, error) {
+	serialized := bytes.NewBuffer(nil)
+	packets := []interface{ Serialize(io.Writer) error }{entity.PrimaryKey}
+	for _, revocation := range entity.Revocations {
+		packets = append(packets, revocation)
+	}
+	for _, revocation := range entity.UnverifiedRevocations {
+		packets = append(packets, revocation)
+	}
+	for _, identity := range entity.Identities {
+		packets = append(packets, identity.UserId, identity.SelfSignature)
+		if identity.Revocation != nil {
+			packets = append(packets, identity.Revocation)
+		}
+		for _, signature := range identity.Signatures {
+			packets = append(packets, signature)
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		packets = append(packets, subkey.PublicKey)
+		if subkey.Revocation != nil {
+			packets = append(packets, subkey.Revocation)
+		}
+		packets = append(packets, subkey.Sig)
+	}
+	for _, p := range packets {
+		if err := p.Serialize(serialized); err != nil {
+			return "", WrapError(err, "failed to serialize public key")
+		}
+	}
+	return base64.StdEncoding.EncodeToString(serialized.Bytes()), nil
+}
+
+// readEntity parses a base64-encoded binary public key, as returned by FetchPublicKeys.
+func readEntity(key string) (*openpgp.Entity, error) {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, WrapError(err, "failed to decode public key")
+	}
+	return openpgp.ReadEntity(packet.NewReader(bytes.NewReader(data)))
+}
+
+func isBase64(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(string(data))
+	return err == nil
+}
@@ -0,0 +1,60 @@
+package locksmith
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ClusterInfo summarizes the cluster state gathered before a ceremony.
+type ClusterInfo struct {
+	Seal   SealStatus
+	Health HealthStatus
+}
+
+func (i ClusterInfo) KeyType() KeyType {
+	if i.Seal.RecoverySeal {
+		return RecoveryKeyType
+	}
+	return UnsealKeyType
+}
+
+func (i ClusterInfo) Summary() string {
+	summary := fmt.Sprintf("%s seal, %d of %d %s key shares required", i.Seal.Type, i.Seal.Threshold, i.Seal.Shares, i.KeyType())
+	if i.Health.Version != "" {
+		summary = fmt.Sprintf("Vault %s, %s", i.Health.Version, summary)
+	}
+	return summary
+}
+
+// Preflight queries sys/seal-status and sys/health and returns an error
+// explaining why the configured ceremony cannot run against the cluster.
+func (c *Client) Preflight() (ClusterInfo, error) {
+	seal, err := c.GetSealStatus()
+	if err != nil {
+		return ClusterInfo{}, WrapError(err, "preflight failed")
+	}
+	health, err := c.GetHealth()
+	if err != nil {
+		return ClusterInfo{}, WrapError(err, "preflight failed")
+	}
+	info := ClusterInfo{Seal: seal, Health: health}
+
+	if !seal.Initialized {
+		return info, errors.New("vault is not initialized, there are no keys to rekey until 'vault operator init' has been run")
+	}
+	if seal.Sealed {
+		return info, fmt.Errorf("vault is sealed, it must be unsealed before a rekey can start (%d/%d unseal shares provided)", seal.Progress, seal.Threshold)
+	}
+	if health.DRSecondary() {
+		return info, errors.New("vault is a DR secondary, rekey operations must be run against the primary cluster")
+	}
+
+	switch {
+	case c.keyType == RecoveryKeyType && !seal.RecoverySeal:
+		return info, fmt.Errorf("vault uses a Shamir seal (%s) without recovery keys, this cluster needs an unseal key ceremony (-key-type unseal)", seal.Type)
+	case c.keyType == UnsealKeyType && seal.RecoverySeal:
+		return info, fmt.Errorf("vault uses an auto-unseal seal (%s), its unseal keys are held by the seal and only recovery keys can be rekeyed (-key-type recovery)", seal.Type)
+	}
+
+	return info, nil
+}
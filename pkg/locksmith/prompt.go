@@ -2,78 +2,179 @@ package locksmith
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func Prompt(prompt string) string {
+// stdin is shared by all prompts, so that buffered input is not lost between
+// prompts when it is piped in.
+var stdin = bufio.NewReader(os.Stdin)
+
+var (
+	// ErrInputClosed is returned by prompts when stdin closes, since no prompt can be answered anymore
+	ErrInputClosed = errors.New("input closed before all prompts were answered")
+	// ErrInterrupted is returned by prompts when Ctrl-C is pressed while the terminal is in raw mode
+	ErrInterrupted = errors.New("interrupted")
+)
+
+func Prompt(prompt string) (string, error) {
 	return promptString(prompt)
 }
 
-func PromptRekeyOptions() StartRekeyRequest {
-	secretShares := promptInt("Number of secret shares")
-	secretThreshold := promptInt("Secret threshold")
+// Confirm asks a yes/no question, defaulting to no.
+func Confirm(prompt string) (bool, error) {
+	input, err := promptString(prompt + " [y/N]")
+	if err != nil {
+		return false, err
+	}
+	input = strings.ToLower(input)
+	return input == "y" || input == "yes", nil
+}
+
+// PromptRekeyOptions prompts for the share count, threshold and participants.
+// Each field is validated as it is entered, and any field can be re-entered before continuing.
+func PromptRekeyOptions() (StartRekeyRequest, error) {
+	var request StartRekeyRequest
+	var err error
+	request.SecretShares, err = promptSecretShares()
+	if err == nil {
+		request.SecretThreshold, err = promptSecretThreshold(request.SecretShares)
+	}
+	if err == nil {
+		request.Participants, err = promptParticipants()
+	}
+	if err != nil {
+		return StartRekeyRequest{}, err
+	}
+
+	for {
+		err := request.Validate()
+		if err == nil {
+			fmt.Printf("Secret shares: %d, threshold: %d, participants: %s\n", request.SecretShares, request.SecretThreshold, strings.Join(ParticipantNames(request.Participants), ", "))
+			confirmed, err := Confirm("Continue with these options?")
+			if err != nil {
+				return StartRekeyRequest{}, err
+			}
+			if confirmed {
+				return request, nil
+			}
+		} else {
+			fmt.Printf("%s.\n", err.Error())
+		}
+
+		field, err := promptString("Field to re-enter (shares, threshold, participants)")
+		if err != nil {
+			return StartRekeyRequest{}, err
+		}
+		switch strings.ToLower(field) {
+		case "shares":
+			request.SecretShares, err = promptSecretShares()
+		case "threshold":
+			request.SecretThreshold, err = promptSecretThreshold(request.SecretShares)
+		case "participants":
+			request.Participants, err = promptParticipants()
+		default:
+			fmt.Println("Unknown field. Please enter shares, threshold or participants.")
+		}
+		if err != nil {
+			return StartRekeyRequest{}, err
+		}
+	}
+}
 
-	var keybaseUsers []string
+func promptSecretShares() (int, error) {
 	for {
-		keybaseUsers = strings.Split(promptString("Keybase users"), ",")
-		if len(keybaseUsers) != secretShares {
-			fmt.Println("Number of keybase users must match secret shares. Please try again.")
+		shares, err := promptInt("Number of secret shares")
+		if err != nil {
+			return 0, err
+		}
+		if shares < 1 || shares > maxSecretShares {
+			fmt.Printf("Secret shares must be between 1 and %d. Please try again.\n", maxSecretShares)
 			continue
 		}
-		break
+		return shares, nil
 	}
+}
 
-	return StartRekeyRequest{
-		SecretShares:    secretShares,
-		SecretThreshold: secretThreshold,
-		KeybaseUsers:    keybaseUsers,
+func promptSecretThreshold(shares int) (int, error) {
+	for {
+		threshold, err := promptInt("Secret threshold")
+		if err != nil {
+			return 0, err
+		}
+		err = ValidateSecretShares(shares, threshold)
+		if err != nil {
+			fmt.Printf("%s. Please try again.\n", err.Error())
+			continue
+		}
+		return threshold, nil
 	}
 }
 
-func promptString(prompt string) string {
-	var input string
-	var err error
+// Participants may mix key sources, e.g. "keybase:alice,bob=file:bob.asc,wkd:carol@example.com"
+func promptParticipants() ([]Participant, error) {
+	for {
+		input, err := promptString("Participants (keybase:user, file:path, base64:key, wkd:email or hkp:fingerprint)")
+		if err != nil {
+			return nil, err
+		}
+		participants, err := ParseParticipants(strings.Split(input, ","))
+		if err != nil {
+			fmt.Printf("%s. Please try again.\n", err.Error())
+			continue
+		}
+		return participants, nil
+	}
+}
 
-	reader := bufio.NewReader(os.Stdin)
+func promptString(prompt string) (string, error) {
 	for {
 		printPrompt(prompt)
-		input, err = reader.ReadString('\n')
+		input, err := readLine()
 		if err != nil {
-			fmt.Println("Error reading input: ", err)
-			continue
+			return "", err
 		}
+		input = strings.TrimSpace(input)
 		if input != "" {
-			break
+			return input, nil
 		}
 	}
-	return strings.TrimSpace(input)
 }
 
-func promptInt(prompt string) int {
-	var input string
-	var result int
-	var err error
-
-	reader := bufio.NewReader(os.Stdin)
+func promptInt(prompt string) (int, error) {
 	for {
-		printPrompt(prompt)
-		input, err = reader.ReadString('\n')
+		input, err := promptString(prompt)
 		if err != nil {
-			fmt.Println("Error reading input: ", err)
-			continue
+			return 0, err
 		}
-		input = strings.TrimSpace(input)
-		result, err = strconv.Atoi(input)
+		result, err := strconv.Atoi(input)
 		if err != nil {
 			fmt.Println("Input must be a valid integer. Please try again.")
 			continue
 		}
-		break
+		return result, nil
+	}
+}
+
+// readLine reads a line from stdin. Input ending without a newline is returned
+// as a final line, and closed input is ErrInputClosed.
+func readLine() (string, error) {
+	input, err := stdin.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		if input != "" {
+			return input, nil
+		}
+		fmt.Println()
+		return "", ErrInputClosed
+	}
+	if err != nil {
+		return "", WrapError(err, "failed to read input")
 	}
-	return result
+	return input, nil
 }
 
 func printPrompt(prompt string) {
@@ -0,0 +1,97 @@
+package locksmith
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Roster pins each participant to the PGP fingerprint they are expected to use.
+// Entries are keyed by participant name or spec, e.g.
+//
+//	{"alice": "6756 731D BEE8 ...", "keybase:bob": "1A65..."}
+type Roster map[string]string
+
+func LoadRoster(path string) (Roster, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, WrapError(err, "failed to read roster file")
+	}
+	var roster Roster
+	err = json.Unmarshal(contents, &roster)
+	if err != nil {
+		return nil, WrapError(err, "failed to parse roster file")
+	}
+	if len(roster) == 0 {
+		return nil, errors.New("roster file contains no participants: " + path)
+	}
+	for name, fingerprint := range roster {
+		roster[name] = normalizeFingerprint(fingerprint)
+	}
+	return roster, nil
+}
+
+// Fingerprint returns the pinned fingerprint of a participant.
+func (r Roster) Fingerprint(participant Participant) (string, bool) {
+	for _, key := range []string{participant.Name, participant.String()} {
+		if fingerprint, ok := r[key]; ok {
+			return fingerprint, true
+		}
+	}
+	return "", false
+}
+
+// Merge combines two rosters. A participant pinned to different fingerprints is an error.
+func (r Roster) Merge(other Roster) (Roster, error) {
+	if r == nil && other == nil {
+		return nil, nil
+	}
+	merged := Roster{}
+	for name, fingerprint := range r {
+		merged[name] = fingerprint
+	}
+	for name, fingerprint := range other {
+		if pinned, ok := merged[name]; ok && pinned != fingerprint {
+			return nil, fmt.Errorf("%s is pinned to both %s and %s", name, pinned, fingerprint)
+		}
+		merged[name] = fingerprint
+	}
+	return merged, nil
+}
+
+// VerifyFingerprints compares fingerprints, in participant order, against the
+// roster. Every participant must be pinned.
+func (r Roster) VerifyFingerprints(participants []Participant, fingerprints []string) error {
+	if len(participants) != len(fingerprints) {
+		return fmt.Errorf("expected %d fingerprints, got %d", len(participants), len(fingerprints))
+	}
+	var mismatches []string
+	for i, participant := range participants {
+		pinned, ok := r.Fingerprint(participant)
+		if !ok {
+			mismatches = append(mismatches, participant.Name+" is not in the roster")
+			continue
+		}
+		actual := normalizeFingerprint(fingerprints[i])
+		if actual != pinned {
+			mismatches = append(mismatches, fmt.Sprintf("%s has fingerprint %s, roster pins %s", participant.Name, actual, pinned))
+		}
+	}
+	if len(mismatches) > 0 {
+		return errors.New("fingerprint mismatch: " + strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// VerifyKeys compares the fingerprints of fetched public keys against the roster.
+func (r Roster) VerifyKeys(checks []KeyCheck) error {
+	var participants []Participant
+	var fingerprints []string
+	for _, check := range checks {
+		participants = append(participants, check.Participant)
+		fingerprints = append(fingerprints, check.Fingerprint)
+	}
+	return r.VerifyFingerprints(participants, fingerprints)
+}
@@ -0,0 +1,289 @@
+package locksmith
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultS3Region = "us-east-1"
+
+// S3Sink uploads the JSON key file to an S3-compatible object store, such as AWS S3 or MinIO.
+// Requests are signed with AWS Signature Version 4, and existing objects are never overwritten.
+type S3Sink struct {
+	// Endpoint defaults to the AWS S3 endpoint of the region
+	Endpoint string
+	Region   string
+	Bucket   string
+	Prefix   string
+	// PathStyle addresses the bucket in the path rather than the host name, as MinIO expects
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	HTTPClient *http.Client
+}
+
+// parseS3Sink parses "s3://bucket/prefix?endpoint=...&region=...&path_style=true".
+// Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func parseS3Sink(spec string) (KeySink, error) {
+	u, query, err := parseS3Spec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := S3Sink{
+		Endpoint:        strings.TrimRight(query.Get("endpoint"), "/"),
+		Region:          query.Get("region"),
+		Bucket:          u.Host,
+		Prefix:          strings.Trim(u.Path, "/"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if sink.Region == "" {
+		sink.Region = os.Getenv("AWS_REGION")
+	}
+	if sink.Region == "" {
+		sink.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if sink.Region == "" {
+		sink.Region = defaultS3Region
+	}
+
+	// Custom endpoints are usually MinIO and friends, which expect path-style requests
+	sink.PathStyle = sink.Endpoint != ""
+	if query.Get("path_style") != "" {
+		sink.PathStyle, err = boolOption(query, "path_style")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if sink.AccessKeyID == "" || sink.SecretAccessKey == "" {
+		return nil, errors.New("s3 sink requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return sink, nil
+}
+
+func parseS3Spec(spec string) (*url.URL, url.Values, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, nil, WrapError(err, "invalid s3 sink")
+	}
+	if u.Host == "" {
+		return nil, nil, errors.New("s3 sink requires a bucket: " + spec)
+	}
+	query, err := sinkOptions(u, "endpoint", "region", "path_style")
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = boolOption(query, "path_style")
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, query, nil
+}
+
+func (s S3Sink) Store(vaultURL string, keys WriteKeysToFileRequest) (string, error) {
+	document, err := EncodeKeyFile(vaultURL, keys)
+	if err != nil {
+		return "", err
+	}
+	key := keysBaseName(keys.keyType()) + ".json"
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("PUT", objectURL.String(), bytes.NewReader(document))
+	if err != nil {
+		return "", WrapError(err, "failed to create s3 request")
+	}
+	req.URL = objectURL
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-None-Match", "*")
+	signV4(req, sha256Hex(document), s.credentials(), s.Region, "s3", time.Now())
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", WrapError(err, "failed to execute s3 request")
+	}
+	defer resp.Body.Close()
+
+	location := "s3://" + s.Bucket + "/" + key
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return "", errors.New("refusing to overwrite existing object: " + location)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", WrapError(decodeS3Error(resp), "failed to upload key file")
+	}
+	return location, nil
+}
+
+func (s S3Sink) String() string {
+	if s.Prefix == "" {
+		return "s3://" + s.Bucket
+	}
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
+
+func (s S3Sink) objectURL(key string) (*url.URL, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("invalid s3 endpoint: " + endpoint)
+	}
+	path := "/" + key
+	if s.PathStyle {
+		path = "/" + s.Bucket + path
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawPath = awsURIEncode(u.Path, false)
+	return u, nil
+}
+
+func (s S3Sink) credentials() awsCredentials {
+	return awsCredentials{
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+	}
+}
+
+func decodeS3Error(resp *http.Response) error {
+	var result struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if xml.Unmarshal(body, &result) != nil || result.Code == "" {
+		return errors.New("unexpected status code: " + resp.Status)
+	}
+	return fmt.Errorf("%s: %s", result.Code, result.Message)
+}
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signV4 signs a request with AWS Signature Version 4, covering every header set on the request.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signV4(req *http.Request, payloadHash string, credentials awsCredentials, region string, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	// Canonical headers, including the host, sorted by lowercase name
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes everything but unreserved characters, and optionally slashes.
+func awsURIEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,143 @@
+package locksmith
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqrcode "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// ScannedShare is an encrypted share reassembled from the QR codes of a printed share.
+type ScannedShare struct {
+	KeyType KeyType
+	SHA256  string
+	Parts   int
+	// Message is the binary PGP message, exactly as Vault returned it
+	Message []byte
+}
+
+// Armored returns the share as the ASCII-armored file written by the armored and paper formats.
+func (s ScannedShare) Armored() ([]byte, error) {
+	return armorShare(s.KeyType, base64.StdEncoding.EncodeToString(s.Message))
+}
+
+// Save writes the armored share to a new file, readable only by the owner.
+func (s ScannedShare) Save(path string) error {
+	armored, err := s.Armored()
+	if err != nil {
+		return WrapError(err, "failed to armor key share")
+	}
+	err = writeFileExclusive(path, armored)
+	if err != nil {
+		return WrapError(err, "failed to write to file")
+	}
+	return nil
+}
+
+// ScanShare reads the QR codes in PNG, JPEG or GIF images, such as photos or scans of
+// a printed share, and reassembles the encrypted share. An image may hold several codes.
+func ScanShare(paths []string) (ScannedShare, error) {
+	var payloads []string
+	for _, path := range paths {
+		found, err := scanQRCodes(path)
+		if err != nil {
+			return ScannedShare{}, err
+		}
+		payloads = append(payloads, found...)
+	}
+	return reassembleShare(payloads)
+}
+
+func scanQRCodes(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, WrapError(err, "failed to open image")
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, WrapError(err, "failed to decode image "+path)
+	}
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, WrapError(err, "failed to read image "+path)
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	var payloads []string
+	results, err := multiqrcode.NewQRCodeMultiReader().DecodeMultiple(bitmap, hints)
+	if err == nil {
+		for _, result := range results {
+			payloads = append(payloads, result.GetText())
+		}
+	}
+	// The multi reader misses some codes that fill the whole image
+	if len(payloads) == 0 {
+		result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+		if err != nil {
+			return nil, errors.New("no QR codes found in " + path)
+		}
+		payloads = append(payloads, result.GetText())
+	}
+	return payloads, nil
+}
+
+func reassembleShare(payloads []string) (ScannedShare, error) {
+	var first *qrChunk
+	parts := map[int]string{}
+	for _, payload := range payloads {
+		// Pages may carry other codes, which are not part of the share
+		if !strings.HasPrefix(strings.TrimSpace(payload), qrChunkPrefix+":") {
+			continue
+		}
+		chunk, err := parseQRChunk(payload)
+		if err != nil {
+			return ScannedShare{}, err
+		}
+		if first == nil {
+			first = &chunk
+		}
+		if chunk.SHA256 != first.SHA256 || chunk.Parts != first.Parts || chunk.KeyType != first.KeyType {
+			return ScannedShare{}, errors.New("QR codes belong to more than one key share, scan one share at a time")
+		}
+		if data, ok := parts[chunk.Part]; ok && data != chunk.Data {
+			return ScannedShare{}, fmt.Errorf("QR codes disagree on part %d of %d", chunk.Part, chunk.Parts)
+		}
+		parts[chunk.Part] = chunk.Data
+	}
+	if first == nil {
+		return ScannedShare{}, errors.New("no locksmith key share QR codes found")
+	}
+
+	var missing []string
+	var encoded strings.Builder
+	for part := 1; part <= first.Parts; part++ {
+		data, ok := parts[part]
+		if !ok {
+			missing = append(missing, strconv.Itoa(part))
+		}
+		encoded.WriteString(data)
+	}
+	if len(missing) > 0 {
+		return ScannedShare{}, fmt.Errorf("missing QR code parts %s of %d", strings.Join(missing, ", "), first.Parts)
+	}
+
+	message, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return ScannedShare{}, WrapError(err, "invalid key share in QR codes")
+	}
+	if sha256Hex(message) != first.SHA256 {
+		return ScannedShare{}, errors.New("reassembled key share does not match its SHA-256, rescan the QR codes")
+	}
+	return ScannedShare{KeyType: first.KeyType, SHA256: first.SHA256, Parts: first.Parts, Message: message}, nil
+}
@@ -0,0 +1,100 @@
+package locksmith
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyCtrlU     = 21
+	keyDelete    = 127
+)
+
+// PromptSecret reads a secret such as a key share without echoing it. When masked,
+// an asterisk is printed for every character typed. Input that is not a
+// terminal, such as a pipe, is read line by line.
+func PromptSecret(prompt string, masked bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return promptString(prompt)
+	}
+
+	for {
+		printPrompt(prompt)
+		var input string
+		var err error
+		if masked {
+			input, err = readMasked(fd)
+		} else {
+			var bytes []byte
+			bytes, err = term.ReadPassword(fd)
+			input = string(bytes)
+			fmt.Println()
+		}
+		if errors.Is(err, io.EOF) {
+			return "", ErrInputClosed
+		}
+		if err != nil {
+			return "", WrapError(err, "failed to read input")
+		}
+		input = strings.TrimSpace(input)
+		if input != "" {
+			return input, nil
+		}
+	}
+}
+
+// readMasked reads a line in raw mode, echoing an asterisk per character.
+func readMasked(fd int) (string, error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+
+	var input []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			continue
+		}
+		switch c := buf[0]; c {
+		case '\r', '\n':
+			fmt.Print("\r\n")
+			return string(input), nil
+		case keyCtrlC:
+			fmt.Print("\r\n")
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(input) == 0 {
+				fmt.Print("\r\n")
+				return "", io.EOF
+			}
+		case keyBackspace, keyDelete:
+			if len(input) > 0 {
+				input = input[:len(input)-1]
+				fmt.Print("\b \b")
+			}
+		case keyCtrlU:
+			fmt.Print(strings.Repeat("\b \b", len(input)))
+			input = input[:0]
+		default:
+			if c >= 32 {
+				input = append(input, c)
+				fmt.Print("*")
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package locksmith
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Vault accepts shares of an AES-128 to AES-256 key, plus one byte of Shamir overhead.
+const (
+	minShareBytes = 16
+	maxShareBytes = 33
+)
+
+// Share is a key share that has passed client-side validation.
+type Share struct {
+	Value    string
+	Encoding string
+	Bytes    int
+	Warnings []string
+}
+
+// ValidateShare checks that input is a hex or base64 key share of a length Vault accepts,
+// removing whitespace introduced by line wraps.
+func ValidateShare(input string) (Share, error) {
+	var share Share
+
+	value := strings.TrimSpace(input)
+	value = strings.Trim(value, "\"'")
+	compact := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, value)
+	if compact != value {
+		share.Warnings = append(share.Warnings, fmt.Sprintf("removed %d whitespace characters, the share may have been wrapped across lines", len(value)-len(compact)))
+	}
+	if compact == "" {
+		return Share{}, fmt.Errorf("key share is empty")
+	}
+	share.Value = compact
+
+	var decoded []byte
+	switch {
+	case isHex(compact):
+		share.Encoding = "hex"
+		if len(compact)%2 != 0 {
+			return Share{}, fmt.Errorf("hex key share has an odd length of %d characters, a character may be missing", len(compact))
+		}
+		decoded, _ = hex.DecodeString(compact)
+	case isBase64Alphabet(compact):
+		share.Encoding = "base64"
+		var err error
+		decoded, err = base64.StdEncoding.DecodeString(compact)
+		if err != nil {
+			return Share{}, WrapError(err, "invalid base64 key share")
+		}
+	default:
+		position, char := firstInvalidChar(compact)
+		return Share{}, fmt.Errorf("key share is neither hex nor base64, unexpected character %q at position %d", char, position+1)
+	}
+
+	share.Bytes = len(decoded)
+	if share.Bytes > maxShareBytes*2 {
+		return Share{}, fmt.Errorf("%s key share decodes to %d bytes, which looks like an encrypted share, decrypt it before submitting", share.Encoding, share.Bytes)
+	}
+	if share.Bytes < minShareBytes || share.Bytes > maxShareBytes {
+		return Share{}, fmt.Errorf("%s key share decodes to %d bytes, expected between %d and %d (%d to %d %s characters)",
+			share.Encoding, share.Bytes, minShareBytes, maxShareBytes, encodedLength(share.Encoding, minShareBytes), encodedLength(share.Encoding, maxShareBytes), share.Encoding)
+	}
+	return share, nil
+}
+
+// PromptShare prompts for a key share until a valid one is entered and confirmed.
+func PromptShare(prompt string, masked bool) (string, error) {
+	for {
+		input, err := PromptSecret(prompt, masked)
+		if err != nil {
+			return "", err
+		}
+		share, err := ValidateShare(input)
+		if err != nil {
+			fmt.Printf("🚫 %s. Please try again.\n", err.Error())
+			continue
+		}
+		for _, warning := range share.Warnings {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+		confirmed, err := Confirm(fmt.Sprintf("Submit %s key share of %d bytes?", share.Encoding, share.Bytes))
+		if err != nil {
+			return "", err
+		}
+		if confirmed {
+			return share.Value, nil
+		}
+	}
+}
+
+func isHex(value string) bool {
+	for _, r := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+func isBase64Alphabet(value string) bool {
+	_, char := firstInvalidChar(value)
+	return char == 0
+}
+
+func firstInvalidChar(value string) (int, rune) {
+	for i, r := range value {
+		if !strings.ContainsRune(base64Alphabet, r) {
+			return i, r
+		}
+	}
+	return -1, 0
+}
+
+func encodedLength(encoding string, bytes int) int {
+	if encoding == "hex" {
+		return hex.EncodedLen(bytes)
+	}
+	return base64.StdEncoding.EncodedLen(bytes)
+}
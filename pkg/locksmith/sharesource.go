@@ -0,0 +1,127 @@
+package locksmith
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// ShareSource reads a key share from a file, stdin or an environment variable, so
+// that a participant can run unattended. At most one source may be set.
+type ShareSource struct {
+	File  string
+	Stdin bool
+	Env   string
+
+	// Shred overwrites and removes File once the share has been used
+	Shred bool
+}
+
+func (s ShareSource) Configured() bool {
+	return s.File != "" || s.Stdin || s.Env != ""
+}
+
+func (s ShareSource) Validate() error {
+	count := 0
+	for _, set := range []bool{s.File != "", s.Stdin, s.Env != ""} {
+		if set {
+			count++
+		}
+	}
+	if count > 1 {
+		return errors.New("only one key share source may be used: a file, stdin or an environment variable")
+	}
+	if s.Shred && s.File == "" {
+		return errors.New("shredding requires a key share file")
+	}
+	return nil
+}
+
+// Describe names the source in messages, without revealing the share.
+func (s ShareSource) Describe() string {
+	switch {
+	case s.File != "":
+		return "file " + s.File
+	case s.Stdin:
+		return "stdin"
+	case s.Env != "":
+		return "environment variable " + s.Env
+	}
+	return "prompt"
+}
+
+// Read reads and validates the key share. A variable is unset once read, so
+// that it is not inherited by child processes.
+func (s ShareSource) Read() (Share, error) {
+	var input string
+	switch {
+	case s.File != "":
+		contents, err := os.ReadFile(s.File)
+		if err != nil {
+			return Share{}, WrapError(err, "failed to read key share file")
+		}
+		input = string(contents)
+	case s.Stdin:
+		// A share is a single line, anything after it is left unread
+		line, err := stdin.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Share{}, WrapError(err, "failed to read key share from stdin")
+		}
+		if strings.TrimSpace(line) == "" {
+			return Share{}, errors.New("no key share on stdin")
+		}
+		input = line
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok || value == "" {
+			return Share{}, errors.New("environment variable " + s.Env + " is not set")
+		}
+		os.Unsetenv(s.Env)
+		input = value
+	default:
+		return Share{}, errors.New("no key share source configured")
+	}
+
+	share, err := ValidateShare(input)
+	if err != nil {
+		return Share{}, WrapError(err, "invalid key share in "+s.Describe())
+	}
+	return share, nil
+}
+
+// Done shreds the key share file, if requested, once the share has been accepted.
+func (s ShareSource) Done() error {
+	if !s.Shred || s.File == "" {
+		return nil
+	}
+	return ShredFile(s.File)
+}
+
+// ShredFile overwrites a file with random data before removing it. Journaling and
+// copy-on-write filesystems may keep earlier copies, so this is best effort.
+func ShredFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return WrapError(err, "failed to open file for shredding")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return WrapError(err, "failed to stat file for shredding")
+	}
+	_, err = io.CopyN(file, rand.Reader, info.Size())
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return WrapError(err, "failed to overwrite file")
+	}
+	err = os.Remove(path)
+	if err != nil {
+		return WrapError(err, "failed to remove file")
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package locksmith
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// KeySink stores the encrypted keys produced by a rekey. Sinks other than LocalSink
+// store the JSON key file document.
+type KeySink interface {
+	// Store saves the keys and returns where they were saved.
+	Store(vaultURL string, keys WriteKeysToFileRequest) (string, error)
+	String() string
+}
+
+// ParseKeySink parses a sink spec:
+//
+//	file:keys                                      a local directory, in the given format
+//	s3://bucket/prefix?endpoint=https://minio:9000  an S3-compatible bucket
+//	vault-kv://secret/locksmith?addr=https://kv:8200 a KV v2 path on another Vault cluster
+//	exec:/usr/local/bin/distribute-keys --notify    a command that reads the JSON key file on stdin
+func ParseKeySink(spec string, format KeyFileFormat) (KeySink, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "file:"):
+		return LocalSink{Directory: strings.TrimPrefix(spec, "file:"), Format: format}, nil
+	case strings.HasPrefix(spec, "exec:"):
+		args := strings.Fields(strings.TrimPrefix(spec, "exec:"))
+		if len(args) == 0 {
+			return nil, errors.New("exec sink requires a command")
+		}
+		return ExecSink{Command: args[0], Args: args[1:]}, nil
+	case strings.HasPrefix(spec, "s3://"):
+		return parseS3Sink(spec)
+	case strings.HasPrefix(spec, "vault-kv://"):
+		return parseVaultKVSink(spec)
+	}
+	return nil, errors.New("invalid key sink, must start with file:, exec:, s3:// or vault-kv://: " + spec)
+}
+
+// ValidateKeySink checks the syntax of a sink spec, without reading credentials or
+// building clients, so a ceremony file can be checked anywhere.
+func ValidateKeySink(spec string) error {
+	spec = strings.TrimSpace(spec)
+	var err error
+	switch {
+	case strings.HasPrefix(spec, "s3://"):
+		_, _, err = parseS3Spec(spec)
+	case strings.HasPrefix(spec, "vault-kv://"):
+		_, _, err = parseVaultKVSpec(spec)
+	default:
+		_, err = ParseKeySink(spec, "")
+	}
+	return err
+}
+
+// LocalSink writes key files to a local directory with WriteKeysToFile.
+type LocalSink struct {
+	Directory string
+	Format    KeyFileFormat
+}
+
+func (s LocalSink) Store(vaultURL string, keys WriteKeysToFileRequest) (string, error) {
+	keys.Directory = s.Directory
+	keys.Format = s.Format
+	return WriteKeysToFile(vaultURL, keys)
+}
+
+func (s LocalSink) String() string {
+	if s.Directory == "" {
+		return "file:."
+	}
+	return "file:" + s.Directory
+}
+
+// ExecSink pipes the JSON key file to a command, which is run directly rather than through a shell.
+// The command also receives LOCKSMITH_VAULT_URL, LOCKSMITH_KEY_TYPE and LOCKSMITH_NONCE.
+type ExecSink struct {
+	Command string
+	Args    []string
+}
+
+func (s ExecSink) Store(vaultURL string, keys WriteKeysToFileRequest) (string, error) {
+	document, err := EncodeKeyFile(vaultURL, keys)
+	if err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(s.Command, s.Args...)
+	cmd.Stdin = bytes.NewReader(document)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"LOCKSMITH_VAULT_URL="+vaultURL,
+		"LOCKSMITH_KEY_TYPE="+string(keys.keyType()),
+		"LOCKSMITH_NONCE="+keys.Nonce,
+	)
+	err = cmd.Run()
+	if err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail != "" {
+			return "", errors.New(err.Error() + ": " + detail)
+		}
+		return "", err
+	}
+	return s.String(), nil
+}
+
+func (s ExecSink) String() string {
+	return "exec:" + strings.Join(append([]string{s.Command}, s.Args...), " ")
+}
+
+// sinkOptions reads the query parameters of a sink spec, rejecting unknown ones.
+func sinkOptions(u *url.URL, known ...string) (url.Values, error) {
+	query := u.Query()
+	for name := range query {
+		found := false
+		for _, option := range known {
+			if name == option {
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.New("unknown " + u.Scheme + " sink option: " + name)
+		}
+	}
+	return query, nil
+}
+
+func boolOption(query url.Values, name string) (bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("invalid value for " + name + ": " + value)
+	}
+	return parsed, nil
+}
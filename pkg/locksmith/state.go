@@ -0,0 +1,286 @@
+package locksmith
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// StateVersion is the version of the state file format
+	StateVersion = 1
+	// StatePassphraseEnv names the environment variable read before prompting for the state file passphrase
+	StatePassphraseEnv = "LOCKSMITH_STATE_PASSPHRASE"
+	// DefaultStateFile is the state file name in the output directory
+	DefaultStateFile = "locksmith-ceremony.state"
+	// scryptN, scryptR and scryptP are the scrypt parameters recommended for interactive logins in 2017
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	// stateSaltSize is the length of the scrypt salt in bytes
+	stateSaltSize = 16
+)
+
+// CeremonyPhase is the last step of a ceremony the leader completed.
+type CeremonyPhase string
+
+const (
+	// StartedPhase means Vault accepted the rekey, and has not yet returned the new keys
+	StartedPhase CeremonyPhase = "started"
+	// KeysReceivedPhase means Vault returned the new keys, and they may only exist in the state file
+	KeysReceivedPhase CeremonyPhase = "keys-received"
+	// KeysSavedPhase means the new keys were saved, and verification is under way
+	KeysSavedPhase CeremonyPhase = "keys-saved"
+)
+
+// CeremonyState is everything the leader needs to resume a ceremony after a crash.
+// Vault returns the new encrypted keys only once, so they are saved as soon as they arrive.
+type CeremonyState struct {
+	Version         int           `json:"version"`
+	Phase           CeremonyPhase `json:"phase"`
+	VaultURL        string        `json:"vault_url"`
+	Namespace       string        `json:"namespace,omitempty"`
+	KeyType         KeyType       `json:"key_type"`
+	Nonce           string        `json:"nonce"`
+	SecretShares    int           `json:"secret_shares"`
+	SecretThreshold int           `json:"secret_threshold"`
+	Participants    []string      `json:"participants"`
+	Identities      []string      `json:"identities"`
+	// Fingerprints are the fingerprints the group confirmed, in participant order
+	Fingerprints      []string  `json:"fingerprints"`
+	StartedAt         time.Time `json:"started_at"`
+	PGPFingerprints   []string  `json:"pgp_fingerprints,omitempty"`
+	Keys              []string  `json:"keys,omitempty"`
+	KeysBase64        []string  `json:"keys_base64,omitempty"`
+	VerificationNonce string    `json:"verification_nonce,omitempty"`
+	// KeysFile is where the local sink saved the keys, empty if they were only printed
+	KeysFile string `json:"keys_file,omitempty"`
+}
+
+// ReceiveKeys records the new keys returned by Vault.
+func (s *CeremonyState) ReceiveKeys(status RekeyStatus) {
+	s.Phase = KeysReceivedPhase
+	s.PGPFingerprints = status.PGPFingerprints
+	s.Keys = status.Keys
+	s.KeysBase64 = status.KeysBase64
+	s.VerificationNonce = status.VerificationNonce
+}
+
+// EncryptedShare returns a participant's new key share, encrypted to their PGP key.
+func (s CeremonyState) EncryptedShare(participant string) (string, error) {
+	for i, name := range s.Participants {
+		if name == participant && i < len(s.KeysBase64) {
+			return s.KeysBase64[i], nil
+		}
+	}
+	return "", fmt.Errorf("participant %s not found in the ceremony, expected one of: %s", participant, strings.Join(s.Participants, ", "))
+}
+
+// KeysRequest returns the new keys, ready to be stored by a KeySink.
+func (s CeremonyState) KeysRequest(directory string, format KeyFileFormat) WriteKeysToFileRequest {
+	return WriteKeysToFileRequest{
+		Namespace:       s.Namespace,
+		KeyType:         s.KeyType,
+		Nonce:           s.Nonce,
+		SecretShares:    s.SecretShares,
+		SecretThreshold: s.SecretThreshold,
+		Participants:    s.Participants,
+		Identities:      s.Identities,
+		PGPFingerprints: s.PGPFingerprints,
+		Keys:            s.Keys,
+		KeysBase64:      s.KeysBase64,
+		Directory:       directory,
+		Format:          format,
+	}
+}
+
+// stateEnvelope is the encrypted state file written to disk.
+type stateEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// additionalData binds the KDF parameters to the ciphertext, so they cannot be altered unnoticed.
+func (e stateEnvelope) additionalData() []byte {
+	return []byte(fmt.Sprintf("locksmith-state:%d:%s:%d:%d:%d", e.Version, e.KDF, e.N, e.R, e.P))
+}
+
+// StateFile is the leader's encrypted record of a ceremony in progress, protected by a passphrase.
+type StateFile struct {
+	Path       string
+	passphrase []byte
+}
+
+// NewStateFile prepares the state file of a new ceremony, taking the passphrase from
+// LOCKSMITH_STATE_PASSPHRASE or a prompt. A state file left by an unfinished ceremony is an error.
+func NewStateFile(path string) (*StateFile, error) {
+	if _, err := os.Lstat(path); err == nil {
+		return nil, errors.New("state file " + path + " exists from an unfinished ceremony, resume it with -resume or remove it")
+	}
+	// Make sure the state file can be written before the rekey starts
+	if dir := filepath.Dir(path); dir != "." {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, WrapError(err, "failed to create state file directory")
+		}
+	}
+	temp, err := writeTempFile(path, nil)
+	if err != nil {
+		return nil, WrapError(err, "state file cannot be written")
+	}
+	os.Remove(temp)
+
+	passphrase := os.Getenv(StatePassphraseEnv)
+	for passphrase == "" {
+		input, err := PromptSecret("Ceremony state file passphrase", false)
+		if err != nil {
+			return nil, err
+		}
+		confirmation, err := PromptSecret("Confirm passphrase", false)
+		if err != nil {
+			return nil, err
+		}
+		if confirmation != input {
+			fmt.Println("Passphrases do not match.")
+			continue
+		}
+		passphrase = input
+	}
+	return &StateFile{Path: path, passphrase: []byte(passphrase)}, nil
+}
+
+// OpenStateFile reads the state of an interrupted ceremony, taking the passphrase from
+// LOCKSMITH_STATE_PASSPHRASE or a prompt.
+func OpenStateFile(path string) (*StateFile, CeremonyState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, CeremonyState{}, WrapError(err, "failed to read state file")
+	}
+	if passphrase := os.Getenv(StatePassphraseEnv); passphrase != "" {
+		file := &StateFile{Path: path, passphrase: []byte(passphrase)}
+		state, err := file.decrypt(data)
+		return file, state, err
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt == maxPassphraseAttempts {
+			return nil, CeremonyState{}, errors.New("incorrect state file passphrase")
+		}
+		passphrase, err := PromptSecret("Ceremony state file passphrase", false)
+		if err != nil {
+			return nil, CeremonyState{}, err
+		}
+		file := &StateFile{Path: path, passphrase: []byte(passphrase)}
+		state, err := file.decrypt(data)
+		if err != errStateDecrypt {
+			return file, state, err
+		}
+	}
+}
+
+var errStateDecrypt = errors.New("incorrect state file passphrase, or the state file is corrupted")
+
+// Save encrypts and replaces the state file, so it always holds the last completed phase.
+func (f *StateFile) Save(state CeremonyState) error {
+	state.Version = StateVersion
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return WrapError(err, "failed to encode ceremony state")
+	}
+
+	envelope := stateEnvelope{Version: StateVersion, KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, stateSaltSize)}
+	_, err = rand.Read(envelope.Salt)
+	if err != nil {
+		return WrapError(err, "failed to generate salt")
+	}
+	aead, err := envelope.cipher(f.passphrase)
+	if err != nil {
+		return err
+	}
+	envelope.Nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(envelope.Nonce)
+	if err != nil {
+		return WrapError(err, "failed to generate nonce")
+	}
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, envelope.additionalData())
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return WrapError(err, "failed to encode state file")
+	}
+	if dir := filepath.Dir(f.Path); dir != "." {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return WrapError(err, "failed to create state file directory")
+		}
+	}
+	err = writeFileAtomic(f.Path, data)
+	if err != nil {
+		return WrapError(err, "failed to write state file")
+	}
+	return nil
+}
+
+// Remove shreds the state file once the ceremony no longer needs it.
+func (f *StateFile) Remove() error {
+	return ShredFile(f.Path)
+}
+
+func (f *StateFile) decrypt(data []byte) (CeremonyState, error) {
+	var envelope stateEnvelope
+	err := json.Unmarshal(data, &envelope)
+	if err != nil {
+		return CeremonyState{}, WrapError(err, "failed to parse state file")
+	}
+	if envelope.Version != StateVersion || envelope.KDF != "scrypt" {
+		return CeremonyState{}, fmt.Errorf("unsupported state file version %d", envelope.Version)
+	}
+	// Parameters are fixed rather than trusted from the file, where a huge N would exhaust memory
+	if envelope.N != scryptN || envelope.R != scryptR || envelope.P != scryptP || len(envelope.Salt) != stateSaltSize {
+		return CeremonyState{}, errors.New("unsupported state file key derivation parameters")
+	}
+	aead, err := envelope.cipher(f.passphrase)
+	if err != nil {
+		return CeremonyState{}, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return CeremonyState{}, errStateDecrypt
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, envelope.additionalData())
+	if err != nil {
+		return CeremonyState{}, errStateDecrypt
+	}
+
+	var state CeremonyState
+	err = json.Unmarshal(plaintext, &state)
+	if err != nil {
+		return CeremonyState{}, WrapError(err, "failed to parse ceremony state")
+	}
+	return state, nil
+}
+
+func (e stateEnvelope) cipher(passphrase []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, e.Salt, e.N, e.R, e.P, 32)
+	if err != nil {
+		return nil, WrapError(err, "failed to derive state file key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,124 @@
+package locksmith
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// TLSConfig mirrors the TLS settings understood by the Vault CLI.
+type TLSConfig struct {
+	CACert     string
+	CAPath     string
+	ClientCert string
+	ClientKey  string
+	ServerName string
+	Insecure   bool
+}
+
+// TLSConfigFromEnv reads the VAULT_CACERT, VAULT_CAPATH, VAULT_CLIENT_CERT,
+// VAULT_CLIENT_KEY, VAULT_TLS_SERVER_NAME and VAULT_SKIP_VERIFY variables.
+func TLSConfigFromEnv() TLSConfig {
+	insecure, _ := strconv.ParseBool(os.Getenv("VAULT_SKIP_VERIFY"))
+	return TLSConfig{
+		CACert:     os.Getenv("VAULT_CACERT"),
+		CAPath:     os.Getenv("VAULT_CAPATH"),
+		ClientCert: os.Getenv("VAULT_CLIENT_CERT"),
+		ClientKey:  os.Getenv("VAULT_CLIENT_KEY"),
+		ServerName: os.Getenv("VAULT_TLS_SERVER_NAME"),
+		Insecure:   insecure,
+	}
+}
+
+func (t TLSConfig) empty() bool {
+	return t == TLSConfig{}
+}
+
+func WithTLSConfig(config TLSConfig) ClientOption {
+	return func(c *Client) error {
+		if config.empty() {
+			return nil
+		}
+		tlsConfig, err := config.build()
+		if err != nil {
+			return err
+		}
+		c.tlsConfig = tlsConfig
+		return nil
+	}
+}
+
+func (t TLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.Insecure,
+	}
+
+	// Trust only the configured CAs, as the Vault CLI does
+	if t.CACert != "" || t.CAPath != "" {
+		pool := x509.NewCertPool()
+		if t.CACert != "" {
+			if err := appendCertFile(pool, t.CACert); err != nil {
+				return nil, err
+			}
+		}
+		if t.CAPath != "" {
+			if err := appendCertDir(pool, t.CAPath); err != nil {
+				return nil, err
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.ClientCert != "" || t.ClientKey != "" {
+		if t.ClientCert == "" || t.ClientKey == "" {
+			return nil, errors.New("both a client certificate and a client key must be provided")
+		}
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, WrapError(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func appendCertFile(pool *x509.CertPool, path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return WrapError(err, "failed to read CA certificate")
+	}
+	if !pool.AppendCertsFromPEM(contents) {
+		return errors.New("no PEM certificates found in " + path)
+	}
+	return nil
+}
+
+func appendCertDir(pool *x509.CertPool, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return WrapError(err, "failed to read CA path")
+	}
+	found := false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return WrapError(err, "failed to read CA certificate")
+		}
+		if pool.AppendCertsFromPEM(contents) {
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("no PEM certificates found in " + dir)
+	}
+	return nil
+}
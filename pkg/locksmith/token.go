@@ -0,0 +1,51 @@
+package locksmith
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tokenHelperFileName = ".vault-token"
+
+// LoadToken resolves a Vault token from the given token file, the VAULT_TOKEN
+// environment variable, or the ~/.vault-token helper file, in that order.
+// An empty token is returned if none of the sources are set.
+func LoadToken(tokenFile string) (string, error) {
+	if tokenFile != "" {
+		token, err := readTokenFile(tokenFile)
+		if err != nil {
+			return "", WrapError(err, "failed to read token file")
+		}
+		if token == "" {
+			return "", errors.New("token file is empty: " + tokenFile)
+		}
+		return token, nil
+	}
+
+	if token := strings.TrimSpace(os.Getenv("VAULT_TOKEN")); token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", nil
+	}
+	token, err := readTokenFile(filepath.Join(home, tokenHelperFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", WrapError(err, "failed to read token helper file")
+	}
+	return token, nil
+}
+
+func readTokenFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
@@ -1,6 +1,9 @@
 package locksmith
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 type RekeyStatus struct {
 	Nonce             string   `json:"nonce"`
@@ -32,8 +35,23 @@ func (r RekeyStatus) ErrorMessage() string {
 	return ""
 }
 
+// invalidKeysErrors are the messages Vault returns when the submitted shares
+// do not reconstruct the current recovery or root key.
+var invalidKeysErrors = []string{
+	"recovery key verification failed",
+	"root key verification failed",
+	// Misspelled by Vault itself when rekeying unseal keys, e.g. in 1.12
+	"rootter key verification failed",
+	"master key verification failed",
+}
+
 func (r RekeyStatus) InvalidKeysError() bool {
-	return r.ErrorMessage() == "recovery key verification failed: recovery key does not match submitted values"
+	for _, message := range invalidKeysErrors {
+		if strings.HasPrefix(r.ErrorMessage(), message) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r RekeyStatus) InProgress() bool {
@@ -87,17 +105,68 @@ func (v VerificationStatus) RemainingKeys() int {
 	return v.Threshold - v.Progress
 }
 
+type SealStatus struct {
+	Type         string `json:"type"`
+	Initialized  bool   `json:"initialized"`
+	Sealed       bool   `json:"sealed"`
+	Threshold    int    `json:"t"`
+	Shares       int    `json:"n"`
+	Progress     int    `json:"progress"`
+	Version      string `json:"version"`
+	RecoverySeal bool   `json:"recovery_seal"`
+	StorageType  string `json:"storage_type"`
+}
+
+type HealthStatus struct {
+	Initialized                bool   `json:"initialized"`
+	Sealed                     bool   `json:"sealed"`
+	Standby                    bool   `json:"standby"`
+	PerformanceStandby         bool   `json:"performance_standby"`
+	ReplicationPerformanceMode string `json:"replication_performance_mode"`
+	ReplicationDRMode          string `json:"replication_dr_mode"`
+	ClusterID                  string `json:"cluster_id"`
+	Version                    string `json:"version"`
+	ClusterName                string `json:"cluster_name"`
+}
+
+func (h HealthStatus) DRSecondary() bool {
+	return h.ReplicationDRMode == "secondary"
+}
+
 type StartRekeyRequest struct {
 	SecretShares    int
 	SecretThreshold int
-	KeybaseUsers    []string
+	Participants    []Participant
+	KeyserverURL    string
+	// PGPKeys are the participants' public keys, as returned by FetchPublicKeys.
+	// They are fetched during StartRekey when empty.
+	PGPKeys []string
+	// Roster, when set, pins the fingerprint each participant's key must have.
+	Roster Roster
 }
 
 type WriteKeysToFileRequest struct {
-	KeybaseUsers    []string
+	Namespace       string
+	KeyType         KeyType
+	Nonce           string
+	SecretShares    int
+	SecretThreshold int
+	Participants    []string
+	// Identities are the user IDs of the participants' keys, written to JSON key files.
+	Identities      []string
 	PGPFingerprints []string
 	Keys            []string
 	KeysBase64      []string
+	Directory       string
+	Format          KeyFileFormat
+}
+
+// keyType is the key type written to key files. An undetected AutoKeyType is a recovery key.
+func (r WriteKeysToFileRequest) keyType() KeyType {
+	if r.KeyType == "" || r.KeyType == AutoKeyType {
+		return RecoveryKeyType
+	}
+	return r.KeyType
 }
 
 type startRekeyRequest struct {
@@ -111,3 +180,12 @@ type submitKeyRequest struct {
 	Key   string `json:"key"`
 	Nonce string `json:"nonce"`
 }
+
+type cancelRequest struct {
+	Nonce string `json:"nonce,omitempty"`
+}
+
+type kvWriteRequest struct {
+	Options map[string]interface{} `json:"options"`
+	Data    map[string]interface{} `json:"data"`
+}
@@ -3,5 +3,5 @@ package locksmith
 import "fmt"
 
 func WrapError(err error, message string) error {
-	return fmt.Errorf("%s; %s", message, err.Error())
+	return fmt.Errorf("%s; %w", message, err)
 }
@@ -0,0 +1,54 @@
+package locksmith
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Vault's Shamir implementation supports at most 255 shares.
+const maxSecretShares = 255
+
+// ValidateSecretShares checks the share count and threshold against the limits Vault enforces.
+func ValidateSecretShares(shares int, threshold int) error {
+	if shares < 1 || shares > maxSecretShares {
+		return fmt.Errorf("secret shares must be between 1 and %d, got %d", maxSecretShares, shares)
+	}
+	if threshold < 1 || threshold > shares {
+		return fmt.Errorf("secret threshold must be between 1 and the number of shares (%d), got %d", shares, threshold)
+	}
+	if shares > 1 && threshold == 1 {
+		return errors.New("secret threshold must be greater than 1 when there is more than one share")
+	}
+	return nil
+}
+
+// Validate checks the rekey options before any key is fetched or Vault is called.
+func (r StartRekeyRequest) Validate() error {
+	err := ValidateSecretShares(r.SecretShares, r.SecretThreshold)
+	if err != nil {
+		return err
+	}
+	if len(r.Participants) != r.SecretShares {
+		return fmt.Errorf("number of participants (%d) must match secret shares (%d)", len(r.Participants), r.SecretShares)
+	}
+	return validateUniqueParticipants(r.Participants)
+}
+
+// validateUniqueParticipants rejects participants listed twice, by name or by key source,
+// since one person would hold several shares.
+func validateUniqueParticipants(participants []Participant) error {
+	names := map[string]bool{}
+	keys := map[string]bool{}
+	for _, participant := range participants {
+		key := string(participant.Source) + ":" + participant.Value
+		if keys[key] {
+			return errors.New("duplicate participant: " + key)
+		}
+		if names[participant.Name] {
+			return errors.New("duplicate participant name: " + participant.Name)
+		}
+		keys[key] = true
+		names[participant.Name] = true
+	}
+	return nil
+}
@@ -1,25 +1,22 @@
 package locksmith
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"net/http"
-
-	"github.com/hashicorp/vault/helper/pgpkeys"
+	"strings"
 )
 
-func GetRekeyStatus(baseURL string) (RekeyStatus, error) {
-	client := &http.Client{}
-	url := baseURL + "/v1/sys/rekey-recovery-key/init"
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) GetRekeyStatus() (RekeyStatus, error) {
+	req, err := c.newRequest("GET", c.keyType.rekeyPath()+"/init", nil)
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to create rekey status request")
 	}
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to execute rekey status request")
 	}
+	defer resp.Body.Close()
 
 	// Parse response
 	var result RekeyStatus
@@ -36,19 +33,33 @@ func GetRekeyStatus(baseURL string) (RekeyStatus, error) {
 	return result, nil
 }
 
-func StartRekey(baseURL string, input StartRekeyRequest) (RekeyStatus, error) {
-	// Fetch public keys from Keybase
-	var users []string
-	for _, user := range input.KeybaseUsers {
-		users = append(users, "keybase:"+user)
+func (c *Client) StartRekey(input StartRekeyRequest) (RekeyStatus, error) {
+	err := input.Validate()
+	if err != nil {
+		return RekeyStatus{}, WrapError(err, "invalid rekey options")
+	}
+
+	// Fetch public keys from Keybase, local files, WKD or a keyserver
+	keys := input.PGPKeys
+	if len(keys) == 0 {
+		fetcher := KeyFetcher{KeyserverURL: input.KeyserverURL}
+		keys, err = fetcher.FetchPublicKeys(input.Participants)
+		if err != nil {
+			return RekeyStatus{}, err
+		}
 	}
-	keyMap, err := pgpkeys.FetchKeybasePubkeys(users)
+
+	// Never hand Vault a key it cannot encrypt a share to
+	checks := CheckPublicKeys(input.Participants, keys)
+	err = KeyCheckErrors(checks)
 	if err != nil {
-		return RekeyStatus{}, WrapError(err, "failed to fetch public keys from Keybase")
+		return RekeyStatus{}, err
 	}
-	var keys []string
-	for _, user := range users {
-		keys = append(keys, keyMap[user])
+	if input.Roster != nil {
+		err = input.Roster.VerifyKeys(checks)
+		if err != nil {
+			return RekeyStatus{}, err
+		}
 	}
 
 	// Build request body
@@ -60,20 +71,15 @@ func StartRekey(baseURL string, input StartRekeyRequest) (RekeyStatus, error) {
 	}
 
 	// Execute request
-	client := &http.Client{}
-	url := baseURL + "/v1/sys/rekey-recovery-key/init"
-	body, err := json.Marshal(startRekeyRequest)
-	if err != nil {
-		return RekeyStatus{}, WrapError(err, "failed to marshal start rekey request")
-	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := c.newRequest("POST", c.keyType.rekeyPath()+"/init", startRekeyRequest)
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to create rekey start request")
 	}
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to execute rekey start request")
 	}
+	defer resp.Body.Close()
 
 	// Parse response
 	var result RekeyStatus
@@ -91,9 +97,9 @@ func StartRekey(baseURL string, input StartRekeyRequest) (RekeyStatus, error) {
 	return result, nil
 }
 
-func SubmitKey(baseURL string, key string) (RekeyStatus, error) {
+func (c *Client) SubmitKey(key string) (RekeyStatus, error) {
 	// Fetch nonce from API
-	status, err := GetRekeyStatus(baseURL)
+	status, err := c.GetRekeyStatus()
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to get rekey status")
 	}
@@ -108,20 +114,15 @@ func SubmitKey(baseURL string, key string) (RekeyStatus, error) {
 	}
 
 	// Execute request
-	client := &http.Client{}
-	url := baseURL + "/v1/sys/rekey-recovery-key/update"
-	body, err := json.Marshal(submitKeyRequest)
-	if err != nil {
-		return RekeyStatus{}, WrapError(err, "failed to marshal submit key request")
-	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := c.newRequest("POST", c.keyType.rekeyPath()+"/update", submitKeyRequest)
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to create submit key request")
 	}
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return RekeyStatus{}, WrapError(err, "failed to execute submit key request")
 	}
+	defer resp.Body.Close()
 
 	// Parse response
 	var result RekeyStatus
@@ -139,18 +140,17 @@ func SubmitKey(baseURL string, key string) (RekeyStatus, error) {
 	return result, nil
 }
 
-func GetVerificationStatus(baseURL string) (VerificationStatus, error) {
+func (c *Client) GetVerificationStatus() (VerificationStatus, error) {
 	// Build & execute request
-	client := &http.Client{}
-	url := baseURL + "/v1/sys/rekey-recovery-key/verify"
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("GET", c.keyType.rekeyPath()+"/verify", nil)
 	if err != nil {
 		return VerificationStatus{}, WrapError(err, "failed to create verification status request")
 	}
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return VerificationStatus{}, WrapError(err, "failed to execute verification status request")
 	}
+	defer resp.Body.Close()
 
 	// Parse response
 	var result VerificationStatus
@@ -161,9 +161,9 @@ func GetVerificationStatus(baseURL string) (VerificationStatus, error) {
 	return result, nil
 }
 
-func SubmitVerification(baseURL string, key string) (VerificationStatus, error) {
+func (c *Client) SubmitVerification(key string) (VerificationStatus, error) {
 	// Fetch nonce from API
-	status, err := GetRekeyStatus(baseURL)
+	status, err := c.GetRekeyStatus()
 	if err != nil {
 		return VerificationStatus{}, WrapError(err, "failed to get rekey status")
 	}
@@ -178,20 +178,15 @@ func SubmitVerification(baseURL string, key string) (VerificationStatus, error)
 	}
 
 	// Execute request
-	client := &http.Client{}
-	url := baseURL + "/v1/sys/rekey-recovery-key/verify"
-	body, err := json.Marshal(submitKeyRequest)
-	if err != nil {
-		return VerificationStatus{}, WrapError(err, "failed to marshal submit key request")
-	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := c.newRequest("POST", c.keyType.rekeyPath()+"/verify", submitKeyRequest)
 	if err != nil {
 		return VerificationStatus{}, WrapError(err, "failed to create submit key request")
 	}
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return VerificationStatus{}, WrapError(err, "failed to execute submit key request")
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		return VerificationStatus{}, errors.New("failed to submit key, unexpected status code: " + resp.Status)
 	}
@@ -211,3 +206,156 @@ func SubmitVerification(baseURL string, key string) (VerificationStatus, error)
 
 	return result, nil
 }
+
+func (c *Client) GetSealStatus() (SealStatus, error) {
+	req, err := c.newRequest("GET", "/v1/sys/seal-status", nil)
+	if err != nil {
+		return SealStatus{}, WrapError(err, "failed to create seal status request")
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return SealStatus{}, WrapError(err, "failed to execute seal status request")
+	}
+	defer resp.Body.Close()
+
+	// Check response
+	if resp.StatusCode != 200 {
+		return SealStatus{}, WrapError(decodeResponseError(resp), "failed to get seal status")
+	}
+
+	// Parse response
+	var result SealStatus
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return SealStatus{}, WrapError(err, "failed to decode seal status response")
+	}
+	return result, nil
+}
+
+// GetHealth returns the node's health. Vault reports sealed, standby and
+// uninitialized nodes with non-200 status codes, so the body is decoded regardless.
+func (c *Client) GetHealth() (HealthStatus, error) {
+	req, err := c.newRequest("GET", "/v1/sys/health", nil)
+	if err != nil {
+		return HealthStatus{}, WrapError(err, "failed to create health request")
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return HealthStatus{}, WrapError(err, "failed to execute health request")
+	}
+	defer resp.Body.Close()
+
+	// Parse response
+	var result HealthStatus
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return HealthStatus{}, WrapError(err, "failed to decode health response")
+	}
+	return result, nil
+}
+
+// CancelRekey cancels the in-progress rekey, including any verification.
+// The current keys remain valid.
+func (c *Client) CancelRekey() error {
+	// Fetch nonce from API
+	status, err := c.GetRekeyStatus()
+	if err != nil {
+		return WrapError(err, "failed to get rekey status")
+	}
+	if !status.InProgress() {
+		return errors.New("no rekey operation in progress")
+	}
+
+	// Execute request
+	req, err := c.newRequest("DELETE", c.keyType.rekeyPath()+"/init", cancelRequest{Nonce: status.Nonce})
+	if err != nil {
+		return WrapError(err, "failed to create cancel rekey request")
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return WrapError(err, "failed to execute cancel rekey request")
+	}
+	defer resp.Body.Close()
+
+	// Check response
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		return WrapError(decodeResponseError(resp), "failed to cancel rekey")
+	}
+	return nil
+}
+
+// RestartVerification discards verification progress and issues a new verification nonce,
+// leaving the rekey itself in place.
+func (c *Client) RestartVerification() (VerificationStatus, error) {
+	// Fetch nonce from API
+	status, err := c.GetRekeyStatus()
+	if err != nil {
+		return VerificationStatus{}, WrapError(err, "failed to get rekey status")
+	}
+	if status.VerificationNonce == "" {
+		return VerificationStatus{}, errors.New("no verification in progress")
+	}
+
+	// Execute request
+	req, err := c.newRequest("DELETE", c.keyType.rekeyPath()+"/verify", cancelRequest{Nonce: status.VerificationNonce})
+	if err != nil {
+		return VerificationStatus{}, WrapError(err, "failed to create restart verification request")
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return VerificationStatus{}, WrapError(err, "failed to execute restart verification request")
+	}
+	defer resp.Body.Close()
+
+	// Check response
+	if resp.StatusCode != 200 {
+		return VerificationStatus{}, WrapError(decodeResponseError(resp), "failed to restart verification")
+	}
+
+	// Parse response
+	var result VerificationStatus
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return VerificationStatus{}, WrapError(err, "failed to decode verification status response")
+	}
+	return result, nil
+}
+
+// WriteKVSecret creates a KV version 2 secret. Existing secrets are never overwritten.
+func (c *Client) WriteKVSecret(mount string, path string, data map[string]interface{}) error {
+	body := kvWriteRequest{
+		Options: map[string]interface{}{"cas": 0},
+		Data:    data,
+	}
+	req, err := c.newRequest("POST", "/v1/"+strings.Trim(mount, "/")+"/data/"+strings.Trim(path, "/"), body)
+	if err != nil {
+		return WrapError(err, "failed to create kv write request")
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return WrapError(err, "failed to execute kv write request")
+	}
+	defer resp.Body.Close()
+
+	// Check response
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		err = decodeResponseError(resp)
+		if strings.Contains(err.Error(), "check-and-set") {
+			return errors.New("refusing to overwrite existing secret: " + mount + "/" + path)
+		}
+		return WrapError(err, "failed to write kv secret")
+	}
+	return nil
+}
+
+// decodeResponseError extracts the first error message from a Vault error response.
+func decodeResponseError(resp *http.Response) error {
+	var result struct {
+		Errors []string `json:"errors"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil || len(result.Errors) == 0 {
+		return errors.New("unexpected status code: " + resp.Status)
+	}
+	return errors.New(result.Errors[0])
+}
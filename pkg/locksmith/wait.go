@@ -5,10 +5,10 @@ import (
 	"time"
 )
 
-func WaitForRekeyStart(vaultURL string) {
+func (c *Client) WaitForRekeyStart() {
 	count := 0
 	for {
-		status, err := GetRekeyStatus(vaultURL)
+		status, err := c.GetRekeyStatus()
 		if err != nil {
 			fmt.Printf("\n%s\n", err.Error())
 			continue
@@ -28,12 +28,12 @@ func WaitForRekeyStart(vaultURL string) {
 	}
 }
 
-func WaitForRekeyCompletion(vaultURL string) {
+func (c *Client) WaitForRekeyCompletion() {
 	count := 0
 	rekeyStarted := false
 	verificationStarted := false
 	for {
-		status, err := GetRekeyStatus(vaultURL)
+		status, err := c.GetRekeyStatus()
 		if err != nil {
 			fmt.Printf("\n%s\n", err.Error())
 			continue
@@ -62,10 +62,10 @@ func WaitForRekeyCompletion(vaultURL string) {
 	}
 }
 
-func WaitForVerificationCompletion(vaultURL string) {
+func (c *Client) WaitForVerificationCompletion() {
 	count := 0
 	for {
-		status, err := GetVerificationStatus(vaultURL)
+		status, err := c.GetVerificationStatus()
 		if err != nil {
 			fmt.Printf("\n%s\n", err.Error())
 			continue
@@ -85,10 +85,10 @@ func WaitForVerificationCompletion(vaultURL string) {
 	}
 }
 
-func WaitForParticipantVerificationSubmissions(vaultURL string) {
+func (c *Client) WaitForParticipantVerificationSubmissions() {
 	count := 0
 	for {
-		status, err := GetVerificationStatus(vaultURL)
+		status, err := c.GetVerificationStatus()
 		if err != nil {
 			fmt.Printf("\n%s\n", err.Error())
 			continue
@@ -111,11 +111,11 @@ func WaitForParticipantVerificationSubmissions(vaultURL string) {
 	}
 }
 
-func WaitForParticipantRekeySubmissions(vaultURL string) {
+func (c *Client) WaitForParticipantRekeySubmissions() {
 	count := 0
 	rekeyStarted := false
 	for {
-		status, err := GetRekeyStatus(vaultURL)
+		status, err := c.GetRekeyStatus()
 		if err != nil {
 			fmt.Printf("\n%s\n", err.Error())
 			continue